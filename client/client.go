@@ -7,8 +7,13 @@ import (
 	"fmt"
 	"github.com/tomasdemarco/go-pos/context"
 	"github.com/tomasdemarco/go-pos/header"
+	"github.com/tomasdemarco/go-pos/journal"
 	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/mti"
+	"github.com/tomasdemarco/go-pos/sequence"
+	"github.com/tomasdemarco/go-pos/trace"
 	"github.com/tomasdemarco/go-pos/trailer"
+	"github.com/tomasdemarco/go-pos/validate"
 	"github.com/tomasdemarco/iso8583/length"
 	"github.com/tomasdemarco/iso8583/message"
 	"github.com/tomasdemarco/iso8583/packager"
@@ -16,6 +21,7 @@ import (
 	"io"
 	"net"
 	"runtime/debug"
+	"strconv"
 	"time"
 )
 
@@ -26,22 +32,31 @@ type Client struct {
 	Port                int
 	Timeout             time.Duration
 	AutoReconnect       bool
-	Conn                *net.TCPConn
+	Conn                net.Conn
+	Dial                DialFunc
 	Reader              *bufio.Reader
 	Writer              *context.SafeWriter
 	RemoteAddr          string
 	OngoingTransactions *OngoingTransactions
 	Packager            *packager.Packager
+	PackagerFunc        func() *packager.Packager
 	MatchFields         []int
-	Stan                *utils.Stan
+	Stan                sequence.Sequence
+	StampPolicy         *StampPolicy
+	Validate            bool
 	Logger              *logger.Logger
+	TraceExporter       trace.Exporter
+	AttributeExtractor  context.Extractor
+	Journal             journal.Journal
 	LengthPackFunc      length.PackFunc
 	LengthUnpackFunc    length.UnpackFunc
 	HeaderPackFunc      header.PackFunc
 	HeaderUnpackFunc    header.UnpackFunc
 	TrailerPackFunc     trailer.PackFunc
 	TrailerUnpackFunc   trailer.UnpackFunc
+	UnmatchedFunc       HandlerFunc
 
+	serverCtx          *context.ServerContext
 	readServerTimeout  time.Duration
 	readMessageTimeout time.Duration
 	maxMessageSize     int
@@ -49,6 +64,9 @@ type Client struct {
 
 type HandlerFunc func(*context.RequestContext, *Client)
 
+// DialFunc opens the connection to address, like net.Dial
+type DialFunc func(network, address string) (net.Conn, error)
+
 type ClientOption func(*Client)
 
 func WithName(name string) ClientOption {
@@ -75,12 +93,74 @@ func WithMatchFields(matchFields []int) ClientOption {
 	}
 }
 
+// WithValidation rejects the messages whose fields do not match the patterns of the packager,
+// Send returns validate.Errors with every invalid field
+func WithValidation(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.Validate = enabled
+	}
+}
+
+// WithPackagerFunc takes the packager of each received message from f, e.g. the current version
+// of a packager of a registry. Sent messages are packed with their own packager. Packager is used
+// when f returns nil.
+func WithPackagerFunc(f func() *packager.Packager) ClientOption {
+	return func(c *Client) {
+		c.PackagerFunc = f
+	}
+}
+
+// WithUnmatchedFunc calls handler with the messages that match no ongoing transaction, e.g. the
+// responses that arrive after their timeout. The message is the request of the context.
+func WithUnmatchedFunc(handler HandlerFunc) ClientOption {
+	return func(c *Client) {
+		c.UnmatchedFunc = handler
+	}
+}
+
+// WithStan takes the STAN of the messages from seq, e.g. a persistent sequence of sequence.FileProvider
+func WithStan(seq sequence.Sequence) ClientOption {
+	return func(c *Client) {
+		c.Stan = seq
+	}
+}
+
+// WithDialer opens the connections with dial instead of net.Dial,
+// e.g. to connect through a proxy or to an in-memory server in tests
+func WithDialer(dial DialFunc) ClientOption {
+	return func(c *Client) {
+		c.Dial = dial
+	}
+}
+
 func WithLogger(logger *logger.Logger) ClientOption {
 	return func(c *Client) {
 		c.Logger = logger
 	}
 }
 
+// WithTraceExporter exports the span of each request when its response is received
+func WithTraceExporter(exporter trace.Exporter) ClientOption {
+	return func(c *Client) {
+		c.TraceExporter = exporter
+	}
+}
+
+// WithAttributeExtractor sets the extractor that adds the content of each sent message
+// to the attributes of its request context
+func WithAttributeExtractor(extractor context.Extractor) ClientOption {
+	return func(c *Client) {
+		c.AttributeExtractor = extractor
+	}
+}
+
+// WithJournal records every sent and received message in j
+func WithJournal(j journal.Journal) ClientOption {
+	return func(c *Client) {
+		c.Journal = j
+	}
+}
+
 func New(
 	host string,
 	port int,
@@ -95,9 +175,11 @@ func New(
 		Timeout:             30 * time.Second,
 		AutoReconnect:       true,
 		Packager:            packager,
+		Dial:                net.Dial,
 		MatchFields:         []int{0, 7, 11},
 		Stan:                utils.NewStan(1, 999999),
 		Logger:              logger.New(logger.Info, "client"),
+		AttributeExtractor:  context.DefaultExtractor,
 		OngoingTransactions: NewOngoingTransactions(),
 		LengthPackFunc:      length.Pack,
 		LengthUnpackFunc:    length.Unpack,
@@ -119,21 +201,20 @@ func New(
 
 // Connect establishes connection to the server
 func (c *Client) Connect() error {
-	tcpAddr, err := net.ResolveTCPAddr(c.Network, fmt.Sprintf("%s:%d", c.Host, c.Port))
-	if err != nil {
-		c.Logger.Error(nil, errors.New(fmt.Sprintf("error connect: %v", err)))
-		return err
-	}
+	address := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
 
-	c.Conn, err = net.DialTCP(c.Network, nil, tcpAddr)
+	conn, err := c.Dial(c.Network, address)
 	if err != nil {
-		c.Logger.Info(nil, logger.Message, fmt.Sprintf("connection refused to %s", tcpAddr.String()))
+		c.Logger.Info(nil, logger.Message, fmt.Sprintf("connection refused to %s", address))
 		return err
 	}
+	c.Conn = conn
 
 	serverContext := context.NewServerContext(c.Conn)
+	c.serverCtx = serverContext
+	c.RemoteAddr = address
 
-	c.Logger.Info(serverContext, logger.Message, fmt.Sprintf("connection established to %s", tcpAddr.String()))
+	c.Logger.Info(serverContext, logger.Message, fmt.Sprintf("connection established to %s", address))
 	c.Reader = bufio.NewReader(c.Conn)
 	c.Writer = context.NewSafeWriter(c.Conn)
 	go func() {
@@ -191,9 +272,11 @@ func (c *Client) Listen(ctx *context.ServerContext) {
 
 	for {
 		_ = c.Conn.SetReadDeadline(time.Now().Add(c.readServerTimeout))
-		lengthVal, err := length.Unpack(c.Reader, c.Packager.Prefix)
+		pkg := c.packager()
+
+		lengthVal, err := c.LengthUnpackFunc(c.Reader, pkg.Prefix)
 		if err != nil {
-			if err != io.EOF {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
 				c.Logger.Error(ctx, err)
 			}
 			break
@@ -210,7 +293,7 @@ func (c *Client) Listen(ctx *context.ServerContext) {
 
 		c.Logger.Debug(ctx, fmt.Sprintf("received message length: %d", lengthVal))
 
-		msgRes := message.NewMessage(c.Packager)
+		msgRes := message.NewMessage(pkg)
 		msgRes.Length = lengthVal
 		headerVal, headerLength, err := c.HeaderUnpackFunc(c.Reader)
 		if err != nil {
@@ -260,50 +343,120 @@ func (c *Client) Listen(ctx *context.ServerContext) {
 			}
 		}
 
-		c.Logger.Debug(ctx, fmt.Sprintf("received a message: %X", msgRaw))
-
 		err = msgRes.Unpack(msgRaw)
 		if err != nil {
+			c.Logger.Debug(ctx, fmt.Sprintf("received a message: %s", c.Logger.MessageHex(nil, msgRaw)))
 			c.Logger.Error(ctx, err)
 		} else {
+			c.Logger.Debug(ctx, fmt.Sprintf("received a message: %s", c.Logger.MessageHex(msgRes, msgRaw)))
+
 			var messageId string
 			for _, v := range c.MatchFields {
 				fld, _ := msgRes.GetField(v)
 				messageId += fld
 			}
 
-			if c.OngoingTransactions.List[messageId].Message != nil || !c.OngoingTransactions.IsChanClosed(messageId) {
-				c.Logger.Debug(c.OngoingTransactions.List[messageId].Ctx, fmt.Sprintf("received a message, id: %s", messageId))
-				c.Logger.Info(c.OngoingTransactions.List[messageId].Ctx, logger.IsoUnpack, fmt.Sprintf("%X", msgRaw))
-				c.Logger.Info(c.OngoingTransactions.List[messageId].Ctx, logger.IsoMessage, msgRes.Log())
-
-				c.OngoingTransactions.List[messageId].Message <- *msgRes
+			if transaction, ok := c.OngoingTransactions.Get(messageId); ok {
+				c.record(transaction.Ctx, journal.Received, msgRes, msgRaw)
+				c.Logger.Debug(transaction.Ctx, fmt.Sprintf("received a message, id: %s", messageId))
+				c.Logger.Info(transaction.Ctx, logger.IsoUnpack, c.Logger.MessageHex(msgRes, msgRaw))
+				c.Logger.Info(transaction.Ctx, logger.IsoMessage, c.Logger.MessageLog(msgRes))
+
+				select {
+				case transaction.Message <- *msgRes:
+				default:
+					c.Logger.Warn(transaction.Ctx, fmt.Sprintf("received a duplicated message, id: %s", messageId))
+				}
 			} else {
+				c.record(ctx, journal.Received, msgRes, msgRaw)
 				c.Logger.Debug(ctx, fmt.Sprintf("received an unmatched message, id: %s", messageId))
-				c.Logger.Info(ctx, logger.IsoUnpack, fmt.Sprintf("%X", msgRaw))
-				c.Logger.Info(c.OngoingTransactions.List[messageId].Ctx, logger.IsoMessage, msgRes.Log())
+				c.Logger.Info(ctx, logger.IsoUnpack, c.Logger.MessageHex(msgRes, msgRaw))
+				c.Logger.Info(ctx, logger.IsoMessage, c.Logger.MessageLog(msgRes))
+
+				if c.UnmatchedFunc != nil {
+					go c.UnmatchedFunc(context.NewRequestContext(nil, msgRes), c)
+				}
 			}
 		}
 	}
 }
 
+// Do sends msg and waits for the server response.
+// When ctx is the context of a request received by a server, the call is made with a child
+// context whose span belongs to the same trace and the time is recorded as its upstream phase.
+func (c *Client) Do(ctx *context.RequestContext, msg *message.Message) (*message.Message, error) {
+	if ctx.ClientCtx != nil {
+		ctx = context.NewChildRequestContext(ctx, msg)
+	}
+
+	err := c.Send(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Wait(ctx)
+}
+
 // Send message for the connection to the server
 func (c *Client) Send(ctx *context.RequestContext, msg *message.Message) error {
+	err := c.send(ctx, msg)
+	if err != nil {
+		c.accessLog(ctx, nil, logger.Failed, err)
+
+		ctx.Span.SetAttribute("error", err.Error())
+		c.stopSpan(ctx)
+	}
+
+	return err
+}
+
+func (c *Client) send(ctx *context.RequestContext, msg *message.Message) error {
+	if c.StampPolicy != nil {
+		err := c.StampPolicy.Stamp(msg, c.Stan)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Validate {
+		err := validate.Message(msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.Extract(c.AttributeExtractor)
+
+	ctx.Span.Name = c.Name
+	if mti, err := msg.GetField(0); err == nil {
+		ctx.Span.SetAttribute("mti", mti)
+	}
+
+	ctx.Span.Begin(trace.PhasePack)
 	messageResponseRaw, err := msg.Pack()
 	if err != nil {
 		return err
 	}
 
 	headerRaw, headerLength, err := c.HeaderPackFunc(msg.Header)
+	if err != nil {
+		return err
+	}
+
 	trailerRaw, trailerLength, err := c.TrailerPackFunc(msg.Trailer)
+	if err != nil {
+		return err
+	}
 
-	lengthPacked, err := length.Pack(c.Packager.Prefix, len(messageResponseRaw)+headerLength+trailerLength)
+	lengthPacked, err := c.LengthPackFunc(msg.Packager.Prefix, len(messageResponseRaw)+headerLength+trailerLength)
 	if err != nil {
 		return err
 	}
 
-	c.Logger.Info(ctx, logger.IsoPack, fmt.Sprintf("%X", messageResponseRaw))
-	c.Logger.Info(ctx, logger.IsoMessage, msg.Log())
+	ctx.Span.Finish(trace.PhasePack)
+
+	c.Logger.Info(ctx, logger.IsoPack, c.Logger.MessageHex(msg, messageResponseRaw))
+	c.Logger.Info(ctx, logger.IsoMessage, c.Logger.MessageLog(msg))
 
 	var messageId string
 	for _, v := range c.MatchFields {
@@ -313,11 +466,11 @@ func (c *Client) Send(ctx *context.RequestContext, msg *message.Message) error {
 				return err
 			}
 
-			mti, err := utils.GetMtiResponse(fld)
+			resMti, err := mti.Response(fld)
 			if err != nil {
 				return err
 			}
-			messageId += mti
+			messageId += resMti
 		} else {
 			fld, err := ctx.Request.GetField(v)
 			if err != nil {
@@ -335,6 +488,7 @@ func (c *Client) Send(ctx *context.RequestContext, msg *message.Message) error {
 	buf.Write(messageResponseRaw)
 	buf.Write(trailerRaw)
 
+	ctx.Span.Begin(trace.PhaseWrite)
 	_, err = c.Writer.Write(buf.Bytes())
 	if err != nil {
 		c.Logger.Error(ctx, err)
@@ -350,7 +504,10 @@ func (c *Client) Send(ctx *context.RequestContext, msg *message.Message) error {
 	}
 
 	if err == nil {
-		c.Logger.Debug(ctx, fmt.Sprintf("sent a message: %X", buf.Bytes()))
+		ctx.Span.Finish(trace.PhaseWrite)
+		c.record(ctx, journal.Sent, msg, messageResponseRaw)
+		ctx.Span.Begin(trace.PhaseUpstream)
+		c.Logger.Debug(ctx, fmt.Sprintf("sent a message: %s", c.Logger.MessageHex(msg, buf.Bytes())))
 		return nil
 	}
 
@@ -364,17 +521,23 @@ func (c *Client) Wait(reqCtx *context.RequestContext) (*message.Message, error)
 		if v == 0 {
 			fld, err := reqCtx.Request.GetField(v)
 			if err != nil {
+				c.accessLog(reqCtx, nil, logger.Failed, err)
+				c.stopSpan(reqCtx)
 				return nil, err
 			}
 
-			mti, err := utils.GetMtiResponse(fld)
+			resMti, err := mti.Response(fld)
 			if err != nil {
+				c.accessLog(reqCtx, nil, logger.Failed, err)
+				c.stopSpan(reqCtx)
 				return nil, err
 			}
-			messageId += mti
+			messageId += resMti
 		} else {
 			fld, err := reqCtx.Request.GetField(v)
 			if err != nil {
+				c.accessLog(reqCtx, nil, logger.Failed, err)
+				c.stopSpan(reqCtx)
 				return nil, err
 			}
 			messageId += fld
@@ -383,12 +546,81 @@ func (c *Client) Wait(reqCtx *context.RequestContext) (*message.Message, error)
 
 	defer c.OngoingTransactions.Remove(messageId)
 
+	transaction, ok := c.OngoingTransactions.Get(messageId)
+	if !ok {
+		err := fmt.Errorf("transaction %s %w", messageId, ErrNotSent)
+		c.accessLog(reqCtx, nil, logger.Failed, err)
+		c.stopSpan(reqCtx)
+		return nil, err
+	}
+
 	select {
 	case <-time.After(c.Timeout - time.Since(reqCtx.StarTime)):
-		return nil, errors.New(fmt.Sprintf("transaction %s timeout", messageId))
-	case msg := <-c.OngoingTransactions.List[messageId].Message:
-		c.Logger.Info(reqCtx, logger.Message, fmt.Sprintf("elapsed time %.3fms", float64(time.Since(reqCtx.StarTime).Nanoseconds())/1e6))
+		err := fmt.Errorf("transaction %s %w", messageId, ErrTimeout)
+		c.accessLog(reqCtx, nil, logger.Timeout, err)
+
+		reqCtx.Span.SetAttribute("error", err.Error())
+		c.stopSpan(reqCtx)
+		return nil, err
+	case msg := <-transaction.Message:
+		reqCtx.Response = &msg
+		reqCtx.EndTime = time.Now()
+		c.accessLog(reqCtx, &msg, logger.OutcomeOf(&msg), nil)
+
+		reqCtx.Span.Finish(trace.PhaseUpstream)
+		c.stopSpan(reqCtx)
 		c.Logger.Debug(reqCtx, fmt.Sprintf("received a message channel, id: %s", messageId))
 		return &msg, nil
 	}
 }
+
+// packager returns the packager for the next received message
+func (c *Client) packager() *packager.Packager {
+	if c.PackagerFunc != nil {
+		if pkg := c.PackagerFunc(); pkg != nil {
+			return pkg
+		}
+	}
+
+	return c.Packager
+}
+
+// stopSpan ends the span of the request and exports it. When the request was made
+// while handling a server request, the call is recorded as the upstream phase of its span.
+func (c *Client) stopSpan(ctx *context.RequestContext) {
+	err := ctx.Span.Stop(c.TraceExporter)
+	if err != nil {
+		c.Logger.Error(ctx, fmt.Errorf("export span: %w", err))
+	}
+
+	if ctx.Parent != nil && ctx.Parent.Span != nil {
+		ctx.Parent.Span.AddPhase(trace.PhaseUpstream, ctx.StarTime, time.Now())
+	}
+}
+
+// record appends msg to the journal when one is set
+func (c *Client) record(ctx context.Context, direction journal.Direction, msg *message.Message, msgRaw []byte) {
+	if c.Journal == nil {
+		return
+	}
+
+	err := c.Journal.Append(journal.NewEntry(c.Name, direction, ctx, msg, msgRaw, c.Logger.Masking))
+	if err != nil {
+		c.Logger.Error(ctx, fmt.Errorf("journal: %w", err))
+	}
+}
+
+// accessLog logs the access log entry of the request in ctx
+func (c *Client) accessLog(ctx *context.RequestContext, response *message.Message, outcome logger.Outcome, err error) {
+	entry := logger.NewLogEntry(ctx, response, outcome)
+	entry.RemoteAddr = c.RemoteAddr
+	if c.serverCtx != nil {
+		entry.ConnId = c.serverCtx.Id.String()
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	c.Logger.Access(ctx, entry)
+}
@@ -0,0 +1,10 @@
+package client
+
+import "errors"
+
+var (
+	ErrNotSent           = errors.New("not sent")
+	ErrTimeout           = errors.New("timeout")
+	ErrInvalidStan       = errors.New("invalid stan")
+	ErrInvalidStampField = errors.New("field without stamp")
+)
@@ -36,6 +36,16 @@ func (s *OngoingTransactions) Add(ctx *context.RequestContext, key string) chan
 	return msgChan
 }
 
+// Get returns the ongoing transaction with id
+func (s *OngoingTransactions) Get(id string) (OngoingTransaction, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	transaction, ok := s.List[id]
+
+	return transaction, ok
+}
+
 func (s *OngoingTransactions) Remove(id string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
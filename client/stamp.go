@@ -0,0 +1,117 @@
+package client
+
+import (
+	"fmt"
+	"github.com/tomasdemarco/go-pos/sequence"
+	"github.com/tomasdemarco/iso8583/message"
+	"strconv"
+	"time"
+)
+
+// StampPolicy sets the trace fields of the messages that are sent without them:
+//
+//	DE7  transmission date and time, MMDDhhmmss in GMT
+//	DE11 STAN, the next value of the client STAN
+//	DE12 local time, hhmmss in Location
+//	DE13 local date, MMDD in Location
+//	DE37 retrieval reference number, built by RrnFunc from the local time and the STAN,
+//	     or from the next number of RrnSequence when it is set
+//
+// Only the fields in Fields that the packager defines are set, fields already present are kept.
+type StampPolicy struct {
+	Fields   []int
+	Now      func() time.Time
+	Location *time.Location
+	RrnFunc  RrnFunc
+
+	RrnSequence sequence.Sequence
+}
+
+// RrnFunc returns the retrieval reference number of a message sent at t with the number stan
+type RrnFunc func(t time.Time, stan int) string
+
+// DefaultStampPolicy sets DE7, DE11, DE12 and DE13 with the current time in the local time zone
+func DefaultStampPolicy() *StampPolicy {
+	return &StampPolicy{
+		Fields:   []int{7, 11, 12, 13},
+		Now:      time.Now,
+		Location: time.Local,
+		RrnFunc:  Rrn,
+	}
+}
+
+// WithStampPolicy sets the trace fields of every sent message that does not have them
+func WithStampPolicy(policy *StampPolicy) ClientOption {
+	return func(c *Client) {
+		c.StampPolicy = policy
+	}
+}
+
+// Rrn returns a retrieval reference number in the YDDDHHNNNNNN format: last digit of the year,
+// day of the year, hour and the last six digits of the STAN
+func Rrn(t time.Time, stan int) string {
+	return fmt.Sprintf("%d%03d%02d%06d", t.Year()%10, t.YearDay(), t.Hour(), stan%1000000)
+}
+
+// Stamp sets the absent fields of the policy in msg, taking the STAN from stan
+func (p *StampPolicy) Stamp(msg *message.Message, stan sequence.Sequence) error {
+	now := time.Now()
+	if p.Now != nil {
+		now = p.Now()
+	}
+
+	local := now
+	if p.Location != nil {
+		local = now.In(p.Location)
+	}
+
+	for _, fieldId := range p.Fields {
+		if _, ok := msg.Fields[fieldId]; ok {
+			continue
+		}
+
+		if msg.Packager != nil {
+			if _, ok := msg.Packager.Fields[fieldId]; !ok {
+				continue
+			}
+		}
+
+		switch fieldId {
+		case 7:
+			msg.SetField(7, now.UTC().Format("0102150405"))
+		case 11:
+			msg.SetField(11, fmt.Sprintf("%06d", stan.Next()))
+		case 12:
+			msg.SetField(12, local.Format("150405"))
+		case 13:
+			msg.SetField(13, local.Format("0102"))
+		case 37:
+			var number int
+			if p.RrnSequence != nil {
+				number = p.RrnSequence.Next()
+			} else {
+				value, ok := msg.Fields[11]
+				if !ok {
+					value = fmt.Sprintf("%06d", stan.Next())
+					msg.SetField(11, value)
+				}
+
+				var err error
+				number, err = strconv.Atoi(value)
+				if err != nil {
+					return fmt.Errorf("%w: %s", ErrInvalidStan, value)
+				}
+			}
+
+			rrn := p.RrnFunc
+			if rrn == nil {
+				rrn = Rrn
+			}
+			msg.SetField(37, rrn(local, number))
+		default:
+			return fmt.Errorf("%w: %d", ErrInvalidStampField, fieldId)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package client_test
+
+import (
+	"errors"
+	"github.com/tomasdemarco/go-pos/client"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/packager"
+	"testing"
+	"time"
+)
+
+// counter is a sequence that counts the numbers taken from it
+type counter struct {
+	next  int
+	calls int
+}
+
+func (c *counter) Next() int {
+	c.calls++
+	c.next++
+	return c.next
+}
+
+func loadPackager(t *testing.T) *packager.Packager {
+	t.Helper()
+
+	pkg, err := subfield.LoadFromJson("../iso8583/packager", "iso87BPackager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+func newPolicy(fields ...int) *client.StampPolicy {
+	return &client.StampPolicy{
+		Fields:   fields,
+		Now:      func() time.Time { return time.Date(2024, time.March, 5, 15, 4, 5, 0, time.UTC) },
+		Location: time.FixedZone("ART", -3*60*60),
+		RrnFunc:  client.Rrn,
+	}
+}
+
+func TestStamp(t *testing.T) {
+	pkg := loadPackager(t)
+
+	for _, tt := range []struct {
+		name    string
+		fields  []int
+		present map[int]string
+		want    map[int]string
+		// numbers taken from the STAN
+		stans int
+	}{
+		{
+			name:   "trace fields",
+			fields: []int{7, 11, 12, 13},
+			want:   map[int]string{7: "0305150405", 11: "000001", 12: "120405", 13: "0305"},
+			stans:  1,
+		},
+		{
+			name:    "present fields are kept",
+			fields:  []int{7, 11, 12, 13, 37},
+			present: map[int]string{7: "0101000000", 11: "000500", 37: "000000000001"},
+			want:    map[int]string{7: "0101000000", 11: "000500", 12: "120405", 13: "0305", 37: "000000000001"},
+		},
+		{
+			name:    "rrn of the present STAN",
+			fields:  []int{37},
+			present: map[int]string{11: "000500"},
+			want:    map[int]string{11: "000500", 37: "406512000500"},
+		},
+		{
+			// DE37 takes the STAN and sets DE11, which is then present and kept
+			name:   "rrn without STAN",
+			fields: []int{37, 11},
+			want:   map[int]string{11: "000001", 37: "406512000001"},
+			stans:  1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			present := map[int]string{0: "0200"}
+			for fieldId, value := range tt.present {
+				present[fieldId] = value
+			}
+
+			msg := gopostest.NewMessage(pkg, present)
+			stan := &counter{}
+
+			err := newPolicy(tt.fields...).Stamp(msg, stan)
+			if err != nil {
+				t.Fatalf("stamp: %v", err)
+			}
+
+			gopostest.AssertFields(t, msg, tt.want)
+
+			if stan.calls != tt.stans {
+				t.Errorf("%d numbers taken from the STAN, want %d", stan.calls, tt.stans)
+			}
+		})
+	}
+}
+
+func TestStampUndefined(t *testing.T) {
+	pkg := loadPackager(t)
+	delete(pkg.Fields, 12)
+	delete(pkg.Fields, 37)
+
+	msg := gopostest.NewMessage(pkg, map[int]string{0: "0200"})
+	stan := &counter{}
+
+	err := newPolicy(11, 12, 13, 37).Stamp(msg, stan)
+	if err != nil {
+		t.Fatalf("stamp: %v", err)
+	}
+
+	// the fields the packager does not define are skipped
+	gopostest.AssertFields(t, msg, map[int]string{11: "000001", 13: "0305"})
+	gopostest.AssertNoField(t, msg, 12, 37)
+
+	if stan.calls != 1 {
+		t.Errorf("%d numbers taken from the STAN, want 1", stan.calls)
+	}
+}
+
+func TestStampRrnSequence(t *testing.T) {
+	pkg := loadPackager(t)
+
+	policy := newPolicy(11, 37)
+	policy.RrnSequence = &counter{next: 41}
+
+	msg := gopostest.NewMessage(pkg, map[int]string{0: "0200"})
+	stan := &counter{}
+
+	err := policy.Stamp(msg, stan)
+	if err != nil {
+		t.Fatalf("stamp: %v", err)
+	}
+
+	// the RRN takes its number from its own sequence rather than from the STAN
+	gopostest.AssertFields(t, msg, map[int]string{11: "000001", 37: "406512000042"})
+
+	if stan.calls != 1 {
+		t.Errorf("%d numbers taken from the STAN, want 1", stan.calls)
+	}
+}
+
+func TestStampInvalidField(t *testing.T) {
+	pkg := loadPackager(t)
+
+	msg := gopostest.NewMessage(pkg, map[int]string{0: "0200"})
+	err := newPolicy(4).Stamp(msg, &counter{})
+	if !errors.Is(err, client.ErrInvalidStampField) {
+		t.Errorf("stamp returned %v, want %v", err, client.ErrInvalidStampField)
+	}
+
+	msg = gopostest.NewMessage(pkg, map[int]string{0: "0200", 11: "ABCDEF"})
+	err = newPolicy(37).Stamp(msg, &counter{})
+	if !errors.Is(err, client.ErrInvalidStan) {
+		t.Errorf("stamp returned %v, want %v", err, client.ErrInvalidStan)
+	}
+}
+
+func TestRrn(t *testing.T) {
+	for _, tt := range []struct {
+		t    time.Time
+		stan int
+		want string
+	}{
+		{time.Date(2024, time.March, 5, 12, 4, 5, 0, time.UTC), 1, "406512000001"},
+		{time.Date(2025, time.December, 31, 23, 59, 59, 0, time.UTC), 999999, "536523999999"},
+		// the last six digits of the STAN
+		{time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC), 1234567, "000100234567"},
+	} {
+		if got := client.Rrn(tt.t, tt.stan); got != tt.want {
+			t.Errorf("rrn of %s and %d is %q, want %q", tt.t, tt.stan, got, tt.want)
+		}
+	}
+}
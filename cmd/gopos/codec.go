@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/decode"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/length"
+	"io"
+	"os"
+	"strings"
+)
+
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	file := fs.String("f", "", "file with the message, by default the argument or the standard input")
+	binary := fs.Bool("bin", false, "the input is binary instead of hex")
+	framed := fs.Bool("framed", false, "the message starts with the length prefix")
+	headerLength := fs.Int("hlen", -1, "header length in bytes, by default the length of -header")
+	asJson := fs.Bool("json", false, "write the decoded frame as JSON instead of a table")
+
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var input []byte
+	switch {
+	case *file != "":
+		input, err = os.ReadFile(*file)
+	case fs.NArg() > 0:
+		input = []byte(strings.Join(fs.Args(), ""))
+	default:
+		input, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	raw := input
+	if !*binary {
+		raw, err = hex.DecodeString(strings.Join(strings.Fields(string(input)), ""))
+		if err != nil {
+			return fmt.Errorf("invalid hex: %w", err)
+		}
+	}
+
+	pkg, err := cfg.LoadPackager()
+	if err != nil {
+		return err
+	}
+
+	if *headerLength < 0 {
+		headerValue, err := cfg.HeaderBytes()
+		if err != nil {
+			return err
+		}
+		*headerLength = len(headerValue)
+	}
+
+	decoder := decode.New(pkg, decode.WithLengthPrefix(*framed), decode.WithHeaderLength(*headerLength))
+	for fieldId, defs := range subfield.Definitions(pkg) {
+		decoder.Subfields[fieldId] = defs
+	}
+
+	frame, decodeErr := decoder.Decode(raw)
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(frame)
+	} else {
+		err = frame.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	return decodeErr
+}
+
+func runEncode(args []string) error {
+	fs := flag.NewFlagSet("encode", flag.ExitOnError)
+	file := fs.String("f", "", "JSON field file, e.g. 0200.json (also accepted as argument)")
+	framed := fs.Bool("framed", false, "add the length prefix and the header")
+
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	path := *file
+	if path == "" && fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	if path == "" {
+		fs.Usage()
+		return errors.New("missing field file")
+	}
+
+	pkg, err := cfg.LoadPackager()
+	if err != nil {
+		return err
+	}
+
+	msg, err := readMessage(path, pkg)
+	if err != nil {
+		return err
+	}
+
+	raw, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+
+	if *framed {
+		headerValue, err := cfg.HeaderBytes()
+		if err != nil {
+			return err
+		}
+
+		lengthPacked, err := length.Pack(pkg.Prefix, len(headerValue)+len(raw))
+		if err != nil {
+			return err
+		}
+
+		raw = append(append(lengthPacked, headerValue...), raw...)
+	}
+
+	fmt.Printf("%X\n", raw)
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/client"
+	"github.com/tomasdemarco/go-pos/header"
+	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/sequence"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/packager"
+	"github.com/tomasdemarco/iso8583/prefix"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the connection settings shared by every command.
+// It is read from the file given with -config and any flag set explicitly overrides it.
+type Config struct {
+	Host        string    `json:"host"`
+	Port        int       `json:"port"`
+	Packager    string    `json:"packager"`
+	Header      string    `json:"header"`
+	Framing     string    `json:"framing"`
+	Timeout     duration  `json:"timeout"`
+	MatchFields fieldList `json:"matchFields"`
+	LogLevel    string    `json:"logLevel"`
+	StanFile    string    `json:"stanFile"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Host:        "127.0.0.1",
+		Port:        8015,
+		Packager:    "./iso8583/packager/iso87BPackager.json",
+		Framing:     "packager",
+		Timeout:     duration(30 * time.Second),
+		MatchFields: fieldList{7, 11},
+		LogLevel:    "Error",
+	}
+}
+
+// parseConfig parses args with the connection flags plus the flags of the command,
+// then applies the config file and the flags set explicitly on top of it
+func parseConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := defaultConfig()
+
+	configFile := fs.String("config", "", "config file (JSON) with the connection settings")
+	fs.StringVar(&cfg.Host, "host", cfg.Host, "host")
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "port")
+	fs.StringVar(&cfg.Packager, "packager", cfg.Packager, "packager json file")
+	fs.StringVar(&cfg.Header, "header", cfg.Header, "fixed header in hex, e.g. 6000000000")
+	fs.StringVar(&cfg.Framing, "framing", cfg.Framing, "length prefix: packager, binary2, ascii4 or bcd4")
+	fs.Var(&cfg.Timeout, "timeout", "response timeout")
+	fs.Var(&cfg.MatchFields, "match", "comma separated fields used to match responses")
+	fs.StringVar(&cfg.LogLevel, "log", cfg.LogLevel, "log level: Debug, Info, Warn or Error")
+	fs.StringVar(&cfg.StanFile, "stan", cfg.StanFile, "file that keeps the STAN of each host and port between runs")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if *configFile == "" {
+		return cfg, nil
+	}
+
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	b, err := os.ReadFile(*configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", *configFile, err)
+	}
+
+	for name, value := range explicit {
+		_ = fs.Set(name, value)
+	}
+
+	return cfg, nil
+}
+
+// LoadPackager loads the packager file with its subfields and applies the framing
+func (cfg *Config) LoadPackager() (*packager.Packager, error) {
+	pkg, err := subfield.LoadFromJson(filepath.Dir(cfg.Packager), filepath.Base(cfg.Packager))
+	if err != nil {
+		return nil, fmt.Errorf("error load packager - %w", err)
+	}
+
+	prefixer, err := cfg.prefixer(pkg)
+	if err != nil {
+		return nil, err
+	}
+	pkg.Prefix = prefixer
+
+	return pkg, nil
+}
+
+func (cfg *Config) prefixer(pkg *packager.Packager) (prefix.Prefixer, error) {
+	switch cfg.Framing {
+	case "", "packager":
+		return pkg.Prefix, nil
+	case "binary2":
+		return prefix.NewBinaryPrefixer(4, false), nil
+	case "ascii4":
+		return prefix.NewAsciiPrefixer(4, false, false), nil
+	case "bcd4":
+		return prefix.NewBcdPrefixer(4, false, false), nil
+	}
+
+	return nil, fmt.Errorf("invalid framing: %s", cfg.Framing)
+}
+
+// HeaderBytes returns the fixed header
+func (cfg *Config) HeaderBytes() ([]byte, error) {
+	value, err := hex.DecodeString(cfg.Header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	return value, nil
+}
+
+// Logger returns a logger for service at the configured level
+func (cfg *Config) Logger(service string) (*logger.Logger, error) {
+	var level logger.LogLevel
+	err := level.UnmarshalJSON([]byte(strconv.Quote(cfg.LogLevel)))
+	if err != nil {
+		return nil, err
+	}
+
+	return logger.New(level, service), nil
+}
+
+// Client returns a connected client
+func (cfg *Config) Client(name string) (*client.Client, error) {
+	pkg, err := cfg.LoadPackager()
+	if err != nil {
+		return nil, err
+	}
+
+	headerValue, err := cfg.HeaderBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := cfg.Logger(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cli := client.New(
+		cfg.Host,
+		cfg.Port,
+		pkg,
+		client.WithName(name),
+		client.WithTimeout(time.Duration(cfg.Timeout)),
+		client.WithAutoReconnect(false),
+		client.WithMatchFields(cfg.MatchFields),
+		client.WithStampPolicy(client.DefaultStampPolicy()),
+		client.WithLogger(log),
+	)
+
+	if len(headerValue) > 0 {
+		cli.HeaderPackFunc, cli.HeaderUnpackFunc = header.Fixed(headerValue)
+	}
+
+	if cfg.StanFile != "" {
+		provider, err := sequence.NewFileProvider(cfg.StanFile)
+		if err != nil {
+			return nil, err
+		}
+		cli.Stan = provider.Sequence(net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	}
+
+	err = cli.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return cli, nil
+}
+
+// Server returns a server on the configured port
+func (cfg *Config) Server(name string, handler server.HandlerFunc) (*server.Server, error) {
+	pkg, err := cfg.LoadPackager()
+	if err != nil {
+		return nil, err
+	}
+
+	headerValue, err := cfg.HeaderBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := cfg.Logger(name)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := server.New(
+		cfg.Port,
+		pkg,
+		handler,
+		server.WithName(name),
+		server.WithLogger(log),
+	)
+
+	if len(headerValue) > 0 {
+		srv.HeaderPackFunc, srv.HeaderUnpackFunc = header.Fixed(headerValue)
+	}
+
+	return srv, nil
+}
+
+// duration is a time.Duration written as "30s" in the config file
+type duration time.Duration
+
+func (d *duration) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = duration(v)
+	return nil
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	return d.Set(s)
+}
+
+// fieldList is a list of field ids written as "7,11" in a flag
+type fieldList []int
+
+func (l *fieldList) String() string {
+	values := make([]string, len(*l))
+	for i, fieldId := range *l {
+		values[i] = strconv.Itoa(fieldId)
+	}
+
+	return strings.Join(values, ",")
+}
+
+func (l *fieldList) Set(s string) error {
+	var values fieldList
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+
+		fieldId, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid field %q", v)
+		}
+		values = append(values, fieldId)
+	}
+
+	*l = values
+	return nil
+}
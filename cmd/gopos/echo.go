@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/client"
+	"github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/iso8583/message"
+	"time"
+)
+
+func runEcho(args []string) error {
+	fs := flag.NewFlagSet("echo", flag.ExitOnError)
+	processingCode := fs.String("pc", "990000", "processing code (DE3) of the echo test")
+
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cli, err := cfg.Client("gopos")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Disconnect()
+	}()
+
+	response, elapsed, err := echo(cli, *processingCode)
+	if err != nil {
+		return err
+	}
+
+	responseCode, _ := response.GetField(39)
+	fmt.Printf("echo to %s: rc=%s time=%s\n", cli.RemoteAddr, responseCode, elapsed)
+
+	return nil
+}
+
+func runPing(args []string) error {
+	fs := flag.NewFlagSet("ping", flag.ExitOnError)
+	processingCode := fs.String("pc", "990000", "processing code (DE3) of the echo test")
+	count := fs.Int("c", 4, "number of echo tests")
+	interval := fs.Duration("i", time.Second, "interval between echo tests")
+
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cli, err := cfg.Client("gopos")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Disconnect()
+	}()
+
+	var received int
+	var minElapsed, maxElapsed, total time.Duration
+
+	for i := 1; i <= *count; i++ {
+		if i > 1 {
+			time.Sleep(*interval)
+		}
+
+		response, elapsed, err := echo(cli, *processingCode)
+		if err != nil {
+			fmt.Printf("seq=%d %v\n", i, err)
+			continue
+		}
+
+		responseCode, _ := response.GetField(39)
+		fmt.Printf("seq=%d rc=%s time=%s\n", i, responseCode, elapsed)
+
+		if received == 0 || elapsed < minElapsed {
+			minElapsed = elapsed
+		}
+		if elapsed > maxElapsed {
+			maxElapsed = elapsed
+		}
+		total += elapsed
+		received++
+	}
+
+	fmt.Printf("--- %s ping statistics ---\n", cli.RemoteAddr)
+	fmt.Printf("%d sent, %d received, %.1f%% loss\n", *count, received, float64(*count-received)*100/float64(*count))
+	if received > 0 {
+		fmt.Printf("min/avg/max = %s/%s/%s\n", minElapsed, total/time.Duration(received), maxElapsed)
+	}
+
+	if received == 0 {
+		return fmt.Errorf("no response from %s", cli.RemoteAddr)
+	}
+
+	return nil
+}
+
+// echo sends an 0800 network management message and waits for its response
+func echo(cli *client.Client, processingCode string) (*message.Message, time.Duration, error) {
+	msg := message.NewMessage(cli.Packager)
+	msg.SetField(0, "0800")
+	msg.SetField(3, processingCode)
+
+	ctx := context.NewRequestContext(nil, msg)
+
+	response, err := cli.Do(ctx, msg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return response, ctx.EndTime.Sub(ctx.StarTime), nil
+}
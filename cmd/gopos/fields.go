@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// readFields reads a JSON file of field id to value, e.g. {"0": "0200", "3": "000000"}
+func readFields(path string) (map[int]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]string
+	err = json.Unmarshal(b, &values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	fields := make(map[int]string, len(values))
+	for key, value := range values {
+		fieldId, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid field %q", path, key)
+		}
+
+		fields[fieldId] = value
+	}
+
+	return fields, nil
+}
+
+// readMessage builds a message from a JSON field file
+func readMessage(path string, pkg *packager.Packager) (*message.Message, error) {
+	fields, err := readFields(path)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := message.NewMessage(pkg)
+	for fieldId, value := range fields {
+		msg.SetField(fieldId, value)
+	}
+
+	return msg, nil
+}
+
+// writeMessage writes the fields of msg as a JSON object ordered by field id
+func writeMessage(w io.Writer, msg *message.Message) error {
+	fieldIds := make([]int, 0, len(msg.Fields))
+	for fieldId := range msg.Fields {
+		if fieldId != 1 {
+			fieldIds = append(fieldIds, fieldId)
+		}
+	}
+	sort.Ints(fieldIds)
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, fieldId := range fieldIds {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		value, _ := json.Marshal(msg.Fields[fieldId])
+		_, _ = fmt.Fprintf(&buf, "\n  %q: %s", strconv.Itoa(fieldId), value)
+	}
+	buf.WriteString("\n}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/simulator"
+	"path/filepath"
+)
+
+func runListen(args []string) error {
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	mode := fs.String("mode", "approve", "approve: answer with the response code and an authorization code, echo: answer with the request fields")
+	responseCode := fs.String("rc", "00", "response code (DE39) of the approve mode")
+	delay := fs.Duration("delay", 0, "delay before each response")
+	rules := fs.String("rules", "", "rules file of the host simulator, replaces -mode, -rc and -delay")
+
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var sim *simulator.Simulator
+	switch {
+	case *rules != "":
+		sim, err = simulator.LoadFromJson(filepath.Dir(*rules), filepath.Base(*rules))
+		if err != nil {
+			return err
+		}
+	case *mode == "approve":
+		sim = simulator.New(nil, simulator.Action{ResponseCode: *responseCode, Delay: *delay})
+	case *mode == "echo":
+		sim = simulator.New(nil, simulator.Action{Delay: *delay})
+	default:
+		return fmt.Errorf("invalid mode: %s", *mode)
+	}
+
+	srv, err := cfg.Server("gopos", sim.Handle)
+	if err != nil {
+		return err
+	}
+
+	return srv.Run()
+}
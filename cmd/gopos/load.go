@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/load"
+	"os"
+	"sort"
+	"time"
+)
+
+func runLoad(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	rate := fs.Float64("tps", 0, "target transactions per second, 0 sends as fast as the workers allow")
+	concurrency := fs.Int("c", 10, "number of workers (requests in flight at most)")
+	duration := fs.Duration("d", 10*time.Second, "duration of the run")
+	output := fs.String("o", "", "write the report as JSON to this file")
+
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing template field files")
+	}
+
+	templates := make([]load.Template, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		fields, err := readFields(path)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, fields)
+	}
+
+	cli, err := cfg.Client("gopos")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Disconnect()
+	}()
+
+	generator := load.NewGenerator(cli, templates)
+	generator.Rate = *rate
+	generator.Concurrency = *concurrency
+	generator.Duration = *duration
+
+	report, err := generator.Run()
+	if err != nil {
+		return err
+	}
+
+	printReport(report)
+
+	if *output != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(*output, append(b, '\n'), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printReport(r *load.Report) {
+	fmt.Printf("requests %d, responses %d, errors %d in %.1fs: %.1f tps\n", r.Requests, r.Responses, r.Errors, r.Duration, r.Throughput)
+
+	if r.TargetRate > 0 {
+		fmt.Printf("rate %.1f of %.1f tps, late %d, dropped %d\n", r.AchievedRate, r.TargetRate, r.LateSlots, r.DroppedSlots)
+	}
+
+	for _, key := range sortedKeys(r.ResponseCodes) {
+		fmt.Printf("  rc %-8s %d\n", key, r.ResponseCodes[key])
+	}
+	for _, key := range sortedKeys(r.ErrorTypes) {
+		fmt.Printf("  error %-5s %d\n", key, r.ErrorTypes[key])
+	}
+
+	l := r.Latency
+	fmt.Printf("latency ms: min %.3f, mean %.3f, p50 %.3f, p95 %.3f, p99 %.3f, p999 %.3f, max %.3f\n", l.Min, l.Mean, l.P50, l.P95, l.P99, l.P999, l.Max)
+
+	for _, bucket := range l.Histogram {
+		if bucket.Count == 0 {
+			continue
+		}
+
+		if bucket.Le == 0 {
+			fmt.Printf("  > %-6g %d\n", load.HistogramBounds[len(load.HistogramBounds)-1], bucket.Count)
+		} else {
+			fmt.Printf("  <= %-5g %d\n", bucket.Le, bucket.Count)
+		}
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,65 @@
+// Command gopos sends, receives and decodes ISO 8583 messages for testing a host or a terminal link.
+//
+//	gopos send -host 10.72.0.22 -header 6000000000 0200.json
+//	gopos listen -port 8015 -mode approve
+//	gopos listen -port 8015 -rules issuer.json
+//	gopos echo -config host.json
+//	gopos ping -config host.json -c 10
+//	gopos decode -framed -header 6000000000 00DE6000000000020072...
+//	gopos encode 0200.json
+//	gopos load -config host.json -tps 50 -d 1m -o report.json 0200.json
+//	gopos replay -config host.json -speed 0 -fields card.json journal-000001.jsonl
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	run   func(args []string) error
+	usage string
+}
+
+var commands = map[string]command{
+	"send":   {runSend, "send a message built from a JSON field file and print the response"},
+	"listen": {runListen, "run a server that approves or echoes every request, or simulates a host from a rules file"},
+	"echo":   {runEcho, "send an 0800 echo test"},
+	"ping":   {runPing, "send echo tests and report the latency"},
+	"decode": {runDecode, "decode a hex or binary message field by field"},
+	"encode": {runEncode, "encode a JSON field file into a hex message"},
+	"load":   {runLoad, "send requests from templates at a target rate and report throughput and latency"},
+	"replay": {runReplay, "send the requests of a capture or journal again and compare the responses"},
+}
+
+var order = []string{"send", "listen", "echo", "ping", "decode", "encode", "load", "replay"}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "help" && os.Args[1] != "-h" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gopos %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gopos <command> [flags]\n\ncommands:\n")
+	for _, name := range order {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun gopos <command> -h for the flags of each command\n")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/mask"
+	"github.com/tomasdemarco/go-pos/replay"
+	"os"
+	"path/filepath"
+)
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	file := fs.String("f", "", "capture file, JSON lines capture or journal segment (also accepted as argument)")
+	speed := fs.Float64("speed", 1, "pacing factor, 1 original pacing, 2 twice as fast, 0 one after another")
+	fieldsFile := fs.String("fields", "", "JSON field file set in every request, e.g. the PAN and track 2 of a test card for the fields masked in the capture")
+	maskFile := fs.String("mask", "", "JSON masking policy of the journal, the default policy when empty; the diffs are printed masked with it")
+	var ignore fieldList
+	fs.Var(&ignore, "ignore", "comma separated fields not compared, e.g. 37,38")
+
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	path := *file
+	if path == "" && fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	if path == "" {
+		fs.Usage()
+		return errors.New("missing capture file")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	records, err := replay.ReadCapture(f)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+
+	cli, err := cfg.Client("gopos")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Disconnect()
+	}()
+
+	replayer := replay.NewReplayer(cli)
+	replayer.Speed = *speed
+	replayer.IgnoreFields = ignore
+
+	if *maskFile != "" {
+		replayer.Masking, err = mask.LoadFromJson(filepath.Dir(*maskFile), filepath.Base(*maskFile))
+		if err != nil {
+			return err
+		}
+	}
+
+	if *fieldsFile != "" {
+		replayer.Fields, err = readFields(*fieldsFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	summary := replayer.Run(records, func(result replay.Result) {
+		switch {
+		case result.Err != nil:
+			fmt.Printf("#%d error: %v\n", result.Index+1, result.Err)
+		case len(result.Diffs) > 0:
+			fmt.Printf("#%d mismatch (%s)\n", result.Index+1, result.Elapsed)
+			for _, diff := range result.Diffs {
+				fmt.Printf("    %s\n", diff)
+			}
+		default:
+			fmt.Printf("#%d ok (%s)\n", result.Index+1, result.Elapsed)
+		}
+	})
+
+	fmt.Printf("total %d, matched %d, mismatched %d, failed %d\n", summary.Total, summary.Matched, summary.Mismatched, summary.Failed)
+
+	if summary.Mismatched > 0 || summary.Failed > 0 {
+		return fmt.Errorf("%d mismatched and %d failed of %d", summary.Mismatched, summary.Failed, summary.Total)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/context"
+	"os"
+)
+
+func runSend(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	file := fs.String("f", "", "JSON field file, e.g. 0200.json (also accepted as argument)")
+
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	path := *file
+	if path == "" && fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	if path == "" {
+		fs.Usage()
+		return errors.New("missing field file")
+	}
+
+	cli, err := cfg.Client("gopos")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Disconnect()
+	}()
+
+	msg, err := readMessage(path, cli.Packager)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.NewRequestContext(nil, msg)
+
+	response, err := cli.Do(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "response received in %s\n", ctx.EndTime.Sub(ctx.StarTime))
+
+	return writeMessage(os.Stdout, response)
+}
@@ -0,0 +1,476 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/client"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const packagerPath = "../iso8583/packager"
+
+var packagers = []string{
+	"iso87BPackager.json",
+	"iso87BVisaBase1Packager.json",
+	"iso87EAmexPackager.json",
+}
+
+// vector is a golden frame: length prefix, header and message in hex, with the fields it carries.
+// Field 1 is the bitmap as it is unpacked. Subfields holds the values of the fields with subfields.
+type vector struct {
+	Name      string                       `json:"name"`
+	Header    string                       `json:"header"`
+	Frame     string                       `json:"frame"`
+	Fields    map[string]string            `json:"fields"`
+	Subfields map[string]map[string]string `json:"subfields"`
+}
+
+func (v vector) header(t *testing.T) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(v.Header)
+	if err != nil {
+		t.Fatalf("header: %v", err)
+	}
+
+	return b
+}
+
+func (v vector) frame(t *testing.T) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(v.Frame)
+	if err != nil {
+		t.Fatalf("frame: %v", err)
+	}
+
+	return b
+}
+
+// fields returns the fields of the vector by id, without the bitmap
+func (v vector) fields(t *testing.T) map[int]string {
+	t.Helper()
+
+	fields := make(map[int]string, len(v.Fields))
+	for key, value := range v.Fields {
+		fieldId, err := strconv.Atoi(key)
+		if err != nil {
+			t.Fatalf("field %q: %v", key, err)
+		}
+
+		if fieldId != 1 {
+			fields[fieldId] = value
+		}
+	}
+
+	return fields
+}
+
+func loadPackager(t *testing.T, file string) *packager.Packager {
+	t.Helper()
+
+	pkg, err := subfield.LoadFromJson(packagerPath, file)
+	if err != nil {
+		t.Fatalf("load %s: %v", file, err)
+	}
+
+	return pkg
+}
+
+// loadVectors returns the vectors of the packager file, from testdata/<packager>.vectors.json
+func loadVectors(t *testing.T, file string) []vector {
+	t.Helper()
+
+	file = strings.TrimSuffix(file, ".json") + ".vectors.json"
+
+	b, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("read vectors: %v", err)
+	}
+
+	var vectors []vector
+	err = json.Unmarshal(b, &vectors)
+	if err != nil {
+		t.Fatalf("vectors %s: %v", file, err)
+	}
+
+	if len(vectors) == 0 {
+		t.Fatalf("vectors %s: empty", file)
+	}
+
+	return vectors
+}
+
+// forEachVector runs f as a subtest for every vector of every packager
+func forEachVector(t *testing.T, f func(t *testing.T, pkg *packager.Packager, v vector)) {
+	for _, file := range packagers {
+		t.Run(file, func(t *testing.T) {
+			pkg := loadPackager(t, file)
+
+			for _, v := range loadVectors(t, file) {
+				t.Run(v.Name, func(t *testing.T) {
+					f(t, pkg, v)
+				})
+			}
+		})
+	}
+}
+
+// body returns the message of frame, checking the length prefix and the header
+func body(t *testing.T, pkg *packager.Packager, v vector) []byte {
+	t.Helper()
+
+	frame := v.frame(t)
+
+	length, err := pkg.Prefix.DecodeLength(frame, 0)
+	if err != nil {
+		t.Fatalf("decode length: %v", err)
+	}
+
+	prefixLength := pkg.Prefix.GetPackedLength()
+	if length != len(frame)-prefixLength {
+		t.Fatalf("length prefix is %d, the frame carries %d bytes", length, len(frame)-prefixLength)
+	}
+
+	header := v.header(t)
+	if !bytes.HasPrefix(frame[prefixLength:], header) {
+		t.Fatalf("frame does not start with header %X", header)
+	}
+
+	return frame[prefixLength+len(header):]
+}
+
+// TestUnpack unpacks every frame and compares all of its fields, the bitmap included
+func TestUnpack(t *testing.T) {
+	forEachVector(t, func(t *testing.T, pkg *packager.Packager, v vector) {
+		msg := message.NewMessage(pkg)
+		err := msg.Unpack(body(t, pkg, v))
+		if err != nil {
+			t.Fatalf("unpack: %v", err)
+		}
+
+		for key, want := range v.Fields {
+			fieldId, _ := strconv.Atoi(key)
+			if got, ok := msg.Fields[fieldId]; !ok {
+				t.Errorf("field %d is absent, want %q", fieldId, want)
+			} else if got != want {
+				t.Errorf("field %d is %q, want %q", fieldId, got, want)
+			}
+		}
+
+		for fieldId, got := range msg.Fields {
+			if _, ok := v.Fields[strconv.Itoa(fieldId)]; !ok {
+				t.Errorf("unexpected field %d: %q", fieldId, got)
+			}
+		}
+	})
+}
+
+// TestPack packs the fields of every vector and compares the message with the frame
+func TestPack(t *testing.T) {
+	forEachVector(t, func(t *testing.T, pkg *packager.Packager, v vector) {
+		msg := gopostest.NewMessage(pkg, v.fields(t))
+
+		raw, err := msg.Pack()
+		if err != nil {
+			t.Fatalf("pack: %v", err)
+		}
+
+		want := body(t, pkg, v)
+		if !bytes.Equal(raw, want) {
+			t.Errorf("pack:\n got %X\nwant %X", raw, want)
+		}
+	})
+}
+
+// copyFields returns the fields of msg without the bitmap. The unpack of the iso8583 library
+// sets bit 1 even without a secondary bitmap and the pack writes the padded values back to the
+// fields, so a message is copied, like the server does with its responses, before it is packed again.
+func copyFields(msg *message.Message) map[int]string {
+	fields := make(map[int]string, len(msg.Fields))
+	for fieldId, value := range msg.Fields {
+		if fieldId != 1 {
+			fields[fieldId] = value
+		}
+	}
+
+	return fields
+}
+
+// TestRoundTrip unpacks every frame and packs the fields again
+func TestRoundTrip(t *testing.T) {
+	forEachVector(t, func(t *testing.T, pkg *packager.Packager, v vector) {
+		want := body(t, pkg, v)
+
+		msg := message.NewMessage(pkg)
+		err := msg.Unpack(want)
+		if err != nil {
+			t.Fatalf("unpack: %v", err)
+		}
+
+		raw, err := gopostest.NewMessage(pkg, copyFields(msg)).Pack()
+		if err != nil {
+			t.Fatalf("pack: %v", err)
+		}
+
+		if !bytes.Equal(raw, want) {
+			t.Errorf("round trip:\n got %X\nwant %X", raw, want)
+		}
+	})
+}
+
+// TestSubfields decodes the fields with subfields and packs the subfields again
+func TestSubfields(t *testing.T) {
+	forEachVector(t, func(t *testing.T, pkg *packager.Packager, v vector) {
+		msg := gopostest.NewMessage(pkg, v.fields(t))
+
+		for fieldKey, want := range v.Subfields {
+			fieldId, _ := strconv.Atoi(fieldKey)
+
+			values, err := subfield.Values(msg, fieldId)
+			if err != nil {
+				t.Fatalf("field %d: %v", fieldId, err)
+			}
+
+			if len(values) != len(want) {
+				t.Errorf("field %d has %d subfields, want %d", fieldId, len(values), len(want))
+			}
+
+			for subfieldKey, wantValue := range want {
+				subfieldId, _ := strconv.Atoi(subfieldKey)
+				if values[subfieldId] != wantValue {
+					t.Errorf("subfield %d.%d is %q, want %q", fieldId, subfieldId, values[subfieldId], wantValue)
+				}
+			}
+
+			subfields, _ := subfield.Lookup(pkg, fieldId)
+			packed, err := subfields.Pack(values)
+			if err != nil {
+				t.Fatalf("pack field %d: %v", fieldId, err)
+			}
+
+			if packed != msg.Fields[fieldId] {
+				t.Errorf("field %d packs to %s, want %s", fieldId, packed, msg.Fields[fieldId])
+			}
+		}
+	})
+}
+
+// TestEdgeCases packs and unpacks single fields for each padding, encoding and prefix
+func TestEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		packager string
+		fieldId  int
+		value    string
+		raw      string
+		unpacked string
+	}{
+		{"odd length BCD with LL BCD prefix and right parity", "iso87BPackager.json", 2, "4541234567890", "134541234567890" + "0", "4541234567890"},
+		{"even length BCD with LL BCD prefix", "iso87BPackager.json", 2, "4541234567890123", "164541234567890123", "4541234567890123"},
+		{"odd length BCD with LL BINARY prefix and left parity", "iso87BVisaBase1Packager.json", 2, "4541234567890", "0D" + "04541234567890", "4541234567890"},
+		{"odd length BCD with LL BINARY prefix and right parity", "iso87BVisaBase1Packager.json", 32, "12345678901", "0B" + "123456789010", "12345678901"},
+		{"fixed odd length BCD with left parity", "iso87BPackager.json", 22, "051", "0051", "051"},
+		{"fixed odd length BCD with left parity, Visa", "iso87BVisaBase1Packager.json", 49, "032", "0032", "032"},
+		{"track 2 separator as BCD D", "iso87BPackager.json", 35, "4541234567890=2612101", "21" + "4541234567890D26121010", "4541234567890=2612101"},
+		{"BCD fill left", "iso87BPackager.json", 4, "1000", "000000001000", "000000001000"},
+		{"BCD fill right", "iso87BPackager.json", 3, "99", "990000", "990000"},
+		{"ASCII fill right", "iso87BPackager.json", 41, "1234", "3132333420202020", "1234    "},
+		{"EBCDIC fill right", "iso87BVisaBase1Packager.json", 42, "12345", "F1F2F3F4F5" + "40404040404040404040", "12345          "},
+		{"EBCDIC fixed", "iso87BVisaBase1Packager.json", 39, "05", "F0F5", "05"},
+		{"EBCDIC letters and spaces", "iso87BVisaBase1Packager.json", 43, "SHOP *1 Buenos Aires                  AR", "E2C8D6D7405CF140C2A4859596A240C1899985A2" + "404040404040404040404040404040404040" + "C1D9", "SHOP *1 Buenos Aires                  AR"},
+		{"EBCDIC with LL EBCDIC prefix", "iso87EAmexPackager.json", 2, "371449635398431", "F1F5" + "F3F7F1F4F4F9F6F3F5F3F9F8F4F3F1", "371449635398431"},
+		{"EBCDIC with LLLL EBCDIC prefix", "iso87EAmexPackager.json", 111, "KEY", "F0F0F0F3" + "D2C5E8", "KEY"},
+		{"binary with LL BINARY prefix", "iso87BVisaBase1Packager.json", 55, "9F2701809F360200FF", "09" + "9F2701809F360200FF", "9F2701809F360200FF"},
+		{"binary fixed", "iso87BPackager.json", 52, "0123456789ABCDEF", "0123456789ABCDEF", "0123456789ABCDEF"},
+		{"ASCII with LLL BCD prefix", "iso87BPackager.json", 48, "001", "0003" + "303031", "001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := loadPackager(t, tt.packager)
+
+			fldPkg, ok := pkg.Fields[tt.fieldId]
+			if !ok {
+				t.Fatalf("field %d is not in %s", tt.fieldId, tt.packager)
+			}
+
+			raw, _, err := fldPkg.Pack(tt.value)
+			if err != nil {
+				t.Fatalf("pack: %v", err)
+			}
+
+			if got := fmt.Sprintf("%X", raw); got != tt.raw {
+				t.Errorf("pack %q:\n got %s\nwant %s", tt.value, got, tt.raw)
+			}
+
+			value, length, err := fldPkg.Unpack(raw, 0)
+			if err != nil {
+				t.Fatalf("unpack: %v", err)
+			}
+
+			if value != tt.unpacked {
+				t.Errorf("unpack is %q, want %q", value, tt.unpacked)
+			}
+
+			if length != len(raw) {
+				t.Errorf("unpack read %d bytes, want %d", length, len(raw))
+			}
+		})
+	}
+}
+
+// TestLengthPrefix checks the LLLL message length prefix of every packager, two bytes
+// with the length in hex, around the one byte boundary
+func TestLengthPrefix(t *testing.T) {
+	tests := []struct {
+		length int
+		raw    string
+	}{
+		{0x2E, "002E"},
+		{0xFF, "00FF"},
+		{0x100, "0100"},
+		{0x16B, "016B"},
+		{0x0FFF, "0FFF"},
+	}
+
+	for _, file := range packagers {
+		pkg := loadPackager(t, file)
+
+		for _, tt := range tests {
+			raw, err := pkg.Prefix.EncodeLength(tt.length)
+			if err != nil {
+				t.Fatalf("%s: encode %d: %v", file, tt.length, err)
+			}
+
+			if got := fmt.Sprintf("%X", raw); got != tt.raw {
+				t.Errorf("%s: encode %d is %s, want %s", file, tt.length, got, tt.raw)
+			}
+
+			length, err := pkg.Prefix.DecodeLength(raw, 0)
+			if err != nil {
+				t.Fatalf("%s: decode %s: %v", file, tt.raw, err)
+			}
+
+			if length != tt.length {
+				t.Errorf("%s: decode %s is %d, want %d", file, tt.raw, length, tt.length)
+			}
+		}
+	}
+}
+
+// echo answers every request with its fields, so the response frame must be the request frame.
+// The fields of the requests are sent to requests.
+func echo(requests chan<- map[int]string) server.HandlerFunc {
+	return func(c *ctx.RequestContext, s *server.Server) {
+		fields := copyFields(c.Request)
+		requests <- fields
+
+		response := gopostest.NewMessage(s.Packager, fields)
+		response.Header = c.Request.Header
+		_ = s.SendResponse(c, response)
+	}
+}
+
+func newHarness(t *testing.T, pkg *packager.Packager, v vector, requests chan<- map[int]string) *gopostest.Harness {
+	opts := []gopostest.Option{
+		gopostest.WithClientOptions(client.WithStampPolicy(nil)),
+	}
+
+	if v.Header != "" {
+		opts = append(opts, gopostest.WithHeader(v.header(t)))
+	}
+
+	return gopostest.New(t, pkg, echo(requests), opts...)
+}
+
+// assertFields fails the test when got and want do not have the same fields
+func assertFields(t *testing.T, got, want map[int]string) {
+	t.Helper()
+
+	for fieldId, value := range want {
+		if got[fieldId] != value {
+			t.Errorf("field %d is %q, want %q", fieldId, got[fieldId], value)
+		}
+	}
+
+	for fieldId, value := range got {
+		if _, ok := want[fieldId]; !ok {
+			t.Errorf("unexpected field %d: %q", fieldId, value)
+		}
+	}
+}
+
+// TestServerFraming writes the golden frames to the server and reads the echo back
+func TestServerFraming(t *testing.T) {
+	forEachVector(t, func(t *testing.T, pkg *packager.Packager, v vector) {
+		requests := make(chan map[int]string, 1)
+		h := newHarness(t, pkg, v, requests)
+
+		conn := h.Dial()
+		defer conn.Close()
+
+		frame := v.frame(t)
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := conn.Write(frame)
+			errs <- err
+		}()
+
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+		response := make([]byte, len(frame))
+		_, err := io.ReadFull(conn, response)
+		if err != nil {
+			t.Fatalf("read response: %v", err)
+		}
+
+		if err := <-errs; err != nil {
+			t.Fatalf("write frame: %v", err)
+		}
+
+		assertFields(t, <-requests, v.fields(t))
+
+		if !bytes.Equal(response, frame) {
+			t.Errorf("response frame:\n got %X\nwant %X", response, frame)
+		}
+	})
+}
+
+// TestClientFraming sends the fields of the golden frames with the client through the server
+func TestClientFraming(t *testing.T) {
+	forEachVector(t, func(t *testing.T, pkg *packager.Packager, v vector) {
+		requests := make(chan map[int]string, 1)
+		h := newHarness(t, pkg, v, requests)
+
+		fields := v.fields(t)
+
+		frame := h.Frame(gopostest.NewMessage(pkg, fields))
+		if !bytes.Equal(frame, v.frame(t)) {
+			t.Errorf("client frame:\n got %X\nwant %X", frame, v.frame(t))
+		}
+
+		response, err := h.Do(gopostest.NewMessage(pkg, fields))
+		if err != nil {
+			t.Fatalf("do: %v", err)
+		}
+
+		assertFields(t, <-requests, fields)
+		gopostest.AssertFields(t, response, fields)
+	})
+}
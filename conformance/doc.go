@@ -0,0 +1,7 @@
+// Package conformance checks the packagers shipped in iso8583/packager against golden vectors.
+//
+// The vectors in testdata hold, for each packager, frames known to be valid (length prefix,
+// header and message in hex) with the fields they carry. The tests unpack and pack every frame,
+// check the padding, encoding and length prefix edge cases field by field and send the frames
+// through the framing of server.Server and client.Client.
+package conformance
@@ -1,7 +1,9 @@
 package context
 
 import (
-	"fmt"
+	"encoding/json"
+	"log/slog"
+	"sort"
 	"strings"
 )
 
@@ -13,9 +15,39 @@ func (a *Attributes) String() string {
 	}
 
 	var sb strings.Builder
-	for k, v := range *a {
-		sb.WriteString(fmt.Sprintf(",\"%s\":\"%s\"", k, v))
+	for _, k := range a.keys() {
+		key, _ := json.Marshal(k)
+		value, _ := json.Marshal((*a)[k])
+		sb.WriteString(",")
+		sb.Write(key)
+		sb.WriteString(":")
+		sb.Write(value)
 	}
 
 	return sb.String()
 }
+
+// Attrs return the attributes as slog attributes sorted by key
+func (a *Attributes) Attrs() []slog.Attr {
+	if a == nil {
+		return nil
+	}
+
+	attrs := make([]slog.Attr, 0, len(*a))
+	for _, k := range a.keys() {
+		attrs = append(attrs, slog.String(k, (*a)[k]))
+	}
+
+	return attrs
+}
+
+func (a *Attributes) keys() []string {
+	keys := make([]string, 0, len(*a))
+	for k := range *a {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -5,12 +5,15 @@ import (
 	"context"
 	"github.com/google/uuid"
 	"net"
+	"sync"
 	"time"
 )
 
 type ClientContext struct {
-	baseCtx context.Context
-	data    map[any]any
+	baseCtx    context.Context
+	data       map[any]any
+	mu         sync.RWMutex
+	attributes Attributes
 
 	Id         uuid.UUID
 	Conn       net.Conn
@@ -23,6 +26,7 @@ type ClientContext struct {
 
 func NewClientContext(conn net.Conn) *ClientContext {
 	c := ClientContext{
+		baseCtx:    context.Background(),
 		StarTime:   time.Now(),
 		Conn:       conn,
 		Reader:     bufio.NewReader(conn),
@@ -44,7 +48,27 @@ func (c *ClientContext) Attributes() *Attributes {
 		return nil
 	}
 
-	return &Attributes{"connId": c.Id.String()}
+	attributes := Attributes{"connId": c.Id.String()}
+
+	c.mu.RLock()
+	for k, v := range c.attributes {
+		attributes[k] = v
+	}
+	c.mu.RUnlock()
+
+	return &attributes
+}
+
+// SetAttribute adds an attribute that is logged with every record of the connection and its requests
+func (c *ClientContext) SetAttribute(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.attributes == nil {
+		c.attributes = Attributes{}
+	}
+
+	c.attributes[key] = value
 }
 
 // Deadline reenvía la llamada al contexto base.
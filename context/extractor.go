@@ -0,0 +1,69 @@
+package context
+
+import (
+	"fmt"
+	"github.com/tomasdemarco/iso8583/message"
+	"strings"
+)
+
+// Extractor returns the attributes taken from the content of a message
+type Extractor func(msg *message.Message) Attributes
+
+// DefaultExtractor adds the MTI, STAN, terminal ID (DE41), merchant ID (DE42),
+// RRN (DE37) and the NII of a TPDU header
+var DefaultExtractor = Extractors(
+	FieldExtractor("mti", 0),
+	FieldExtractor("stan", 11),
+	FieldExtractor("terminalId", 41),
+	FieldExtractor("merchantId", 42),
+	FieldExtractor("rrn", 37),
+	TpduNiiExtractor("nii"),
+)
+
+// Extractors combines several extractors, later extractors override the keys of previous ones
+func Extractors(extractors ...Extractor) Extractor {
+	return func(msg *message.Message) Attributes {
+		attributes := Attributes{}
+		for _, extractor := range extractors {
+			for k, v := range extractor(msg) {
+				attributes[k] = v
+			}
+		}
+
+		return attributes
+	}
+}
+
+// FieldExtractor adds the value of fieldId, without surrounding spaces, as key
+func FieldExtractor(key string, fieldId int) Extractor {
+	return func(msg *message.Message) Attributes {
+		value, err := msg.GetField(fieldId)
+		if err != nil || strings.TrimSpace(value) == "" {
+			return nil
+		}
+
+		return Attributes{key: strings.TrimSpace(value)}
+	}
+}
+
+// TpduNiiExtractor adds the NII (destination address) of a TPDU header as key.
+// The header can be the raw 5 bytes or their hex representation, starting with the TPDU id 0x60.
+func TpduNiiExtractor(key string) Extractor {
+	return func(msg *message.Message) Attributes {
+		var tpdu string
+		switch header := msg.Header.(type) {
+		case []byte:
+			tpdu = fmt.Sprintf("%X", header)
+		case string:
+			tpdu = strings.ToUpper(header)
+		default:
+			return nil
+		}
+
+		if len(tpdu) != 10 || tpdu[:2] != "60" {
+			return nil
+		}
+
+		return Attributes{key: tpdu[3:6]}
+	}
+}
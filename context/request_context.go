@@ -3,13 +3,17 @@ package context
 import (
 	"context"
 	"github.com/google/uuid"
+	"github.com/tomasdemarco/go-pos/trace"
 	"github.com/tomasdemarco/iso8583/message"
+	"sync"
 	"time"
 )
 
 type RequestContext struct {
-	baseCtx context.Context
-	data    map[any]any
+	baseCtx    context.Context
+	data       map[any]any
+	mu         sync.RWMutex
+	attributes Attributes
 
 	Id        uuid.UUID
 	ClientCtx *ClientContext
@@ -17,13 +21,41 @@ type RequestContext struct {
 	EndTime   time.Time
 	Request   *message.Message
 	Response  *message.Message
+	Span      *trace.Span
+	Parent    *RequestContext
+
+	// ValidationErr holds the invalid fields of the request when the server passes them to the handler
+	ValidationErr error
 }
 
 func NewRequestContext(clientCtx *ClientContext, msgReq *message.Message) *RequestContext {
 	c := RequestContext{
+		baseCtx:   context.Background(),
 		ClientCtx: clientCtx,
 		Request:   msgReq,
 		StarTime:  time.Now(),
+		Span:      trace.NewSpan("request"),
+	}
+
+	if clientCtx != nil {
+		c.baseCtx = clientCtx
+	}
+
+	c.Id = uuid.New()
+
+	return &c
+}
+
+// NewChildRequestContext creates the context of a message sent upstream while handling parent,
+// its span belongs to the trace of parent. The child has no ClientCtx of its own, its records
+// carry the connection attributes of parent.
+func NewChildRequestContext(parent *RequestContext, msgReq *message.Message) *RequestContext {
+	c := RequestContext{
+		baseCtx:  parent,
+		Request:  msgReq,
+		StarTime: time.Now(),
+		Span:     trace.NewChildSpan(parent.Span, "upstream"),
+		Parent:   parent,
 	}
 
 	c.Id = uuid.New()
@@ -36,11 +68,68 @@ func (c *RequestContext) GetId() uuid.UUID {
 }
 
 func (c *RequestContext) Attributes() *Attributes {
-	if c == nil || c.ClientCtx == nil {
+	if c == nil {
 		return nil
 	}
 
-	return &Attributes{"connId": c.ClientCtx.Id.String()}
+	attributes := Attributes{}
+
+	if clientCtx := c.clientCtx(); clientCtx != nil {
+		for k, v := range *clientCtx.Attributes() {
+			attributes[k] = v
+		}
+	}
+
+	if c.Span != nil {
+		attributes["traceId"] = c.Span.TraceId
+		attributes["spanId"] = c.Span.SpanId
+	}
+
+	c.mu.RLock()
+	for k, v := range c.attributes {
+		attributes[k] = v
+	}
+	c.mu.RUnlock()
+
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	return &attributes
+}
+
+// clientCtx returns the connection of the request, or of its closest parent that has one
+func (c *RequestContext) clientCtx() *ClientContext {
+	for p := c; p != nil; p = p.Parent {
+		if p.ClientCtx != nil {
+			return p.ClientCtx
+		}
+	}
+
+	return nil
+}
+
+// SetAttribute adds an attribute that is logged with every record of the request
+func (c *RequestContext) SetAttribute(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.attributes == nil {
+		c.attributes = Attributes{}
+	}
+
+	c.attributes[key] = value
+}
+
+// Extract adds the attributes returned by extractor for the request message
+func (c *RequestContext) Extract(extractor Extractor) {
+	if extractor == nil || c.Request == nil {
+		return
+	}
+
+	for k, v := range extractor(c.Request) {
+		c.SetAttribute(k, v)
+	}
 }
 
 // Deadline reenvía la llamada al contexto base.
@@ -0,0 +1,55 @@
+package context
+
+import (
+	"net"
+	"testing"
+)
+
+func TestChildRequestContextAttributes(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	clientCtx := NewClientContext(conn)
+	clientCtx.SetAttribute("dialect", "visa")
+
+	parent := NewRequestContext(clientCtx, nil)
+	parent.SetAttribute("terminalId", "00000001")
+
+	child := NewChildRequestContext(parent, nil)
+	child.SetAttribute("stan", "000002")
+	grandchild := NewChildRequestContext(child, nil)
+
+	if child.ClientCtx != nil {
+		t.Fatalf("the child has the connection of its parent")
+	}
+
+	for _, c := range []*RequestContext{child, grandchild} {
+		attributes := *c.Attributes()
+
+		if attributes["connId"] != clientCtx.Id.String() || attributes["dialect"] != "visa" {
+			t.Errorf("attributes %v do not have the connection attributes of the parent", attributes)
+		}
+
+		if _, ok := attributes["terminalId"]; ok {
+			t.Errorf("attributes %v have the request attributes of the parent", attributes)
+		}
+
+		if attributes["traceId"] != parent.Span.TraceId || attributes["spanId"] != c.Span.SpanId {
+			t.Errorf("attributes %v are not of the span of the child in the trace of the parent", attributes)
+		}
+	}
+
+	if (*child.Attributes())["stan"] != "000002" {
+		t.Errorf("the child lost its own attributes")
+	}
+}
+
+func TestRequestContextWithoutConnection(t *testing.T) {
+	c := NewRequestContext(nil, nil)
+	child := NewChildRequestContext(c, nil)
+
+	if _, ok := (*child.Attributes())["connId"]; ok {
+		t.Errorf("a request without connection has a connId")
+	}
+}
@@ -24,6 +24,7 @@ type ServerContext struct {
 
 func NewServerContext(conn net.Conn) *ServerContext {
 	c := ServerContext{
+		baseCtx:    context.Background(),
 		StarTime:   time.Now(),
 		Conn:       conn,
 		Reader:     bufio.NewReader(conn),
@@ -0,0 +1,268 @@
+// Package decode unpacks raw messages field by field for troubleshooting,
+// keeping the offset and raw bytes of each field and the exact point where decoding fails.
+package decode
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/go-pos/validate"
+	"github.com/tomasdemarco/iso8583/bitmap"
+	"github.com/tomasdemarco/iso8583/packager"
+	"github.com/tomasdemarco/iso8583/packager/field"
+)
+
+// Decoder unpacks messages with a packager. The input can start with the length prefix
+// of the packager and a header of HeaderLength bytes.
+type Decoder struct {
+	Packager     *packager.Packager
+	Subfields    map[int]subfield.Subfields
+	LengthPrefix bool
+	HeaderLength int
+}
+
+type Option func(*Decoder)
+
+// WithLengthPrefix sets whether the input starts with the length prefix of the packager
+func WithLengthPrefix(lengthPrefix bool) Option {
+	return func(d *Decoder) {
+		d.LengthPrefix = lengthPrefix
+	}
+}
+
+// WithHeaderLength sets the length in bytes of the header before the MTI
+func WithHeaderLength(length int) Option {
+	return func(d *Decoder) {
+		d.HeaderLength = length
+	}
+}
+
+// WithSubfields decodes the subfields of fieldId with the definitions of subfields
+func WithSubfields(fieldId int, subfields subfield.Subfields) Option {
+	return func(d *Decoder) {
+		d.Subfields[fieldId] = subfields
+	}
+}
+
+func New(pkg *packager.Packager, opts ...Option) *Decoder {
+	decoder := Decoder{
+		Packager:  pkg,
+		Subfields: make(map[int]subfield.Subfields),
+	}
+
+	for _, opt := range opts {
+		opt(&decoder)
+	}
+
+	return &decoder
+}
+
+// Decode unpacks raw. When a field cannot be unpacked it returns the fields decoded
+// up to that point together with an *Error that holds the field and its offset.
+func (d *Decoder) Decode(raw []byte) (*Frame, error) {
+	frame := &Frame{}
+	offset := 0
+	end := len(raw)
+
+	if d.LengthPrefix {
+		prefixLength := d.Packager.Prefix.GetPackedLength()
+		if len(raw) < prefixLength {
+			return frame, fmt.Errorf("%w: %d bytes expected, got %d", ErrInvalidLength, prefixLength, len(raw))
+		}
+
+		length, err := d.Packager.Prefix.DecodeLength(raw, 0)
+		if err != nil {
+			return frame, fmt.Errorf("%w: %w", ErrInvalidLength, err)
+		}
+
+		frame.Length = length
+		offset = prefixLength
+
+		if offset+length > len(raw) {
+			return frame, fmt.Errorf("%w: length %d exceeds the %d bytes after the prefix", ErrInvalidLength, length, len(raw)-offset)
+		}
+		end = offset + length
+	}
+
+	if d.HeaderLength > 0 {
+		if offset+d.HeaderLength > end {
+			return frame, fmt.Errorf("%w: %d bytes expected at offset %d, got %d", ErrInvalidHeader, d.HeaderLength, offset, end-offset)
+		}
+
+		frame.Header = fmt.Sprintf("%X", raw[offset:offset+d.HeaderLength])
+		offset += d.HeaderLength
+	}
+
+	defer func() {
+		if offset < len(raw) {
+			frame.Trailing = fmt.Sprintf("%X", raw[offset:])
+		}
+	}()
+
+	msgRaw := raw[:end]
+
+	mtiPkg, ok := d.Packager.Fields[0]
+	if !ok {
+		return frame, &Error{FieldId: 0, Offset: offset, Err: ErrNotFoundInPackager}
+	}
+
+	mti, length, err := unpack(0, mtiPkg, msgRaw, offset)
+	if err != nil {
+		return frame, err
+	}
+	frame.Fields = append(frame.Fields, mti)
+	offset += length
+
+	bitmapPkg, ok := d.Packager.Fields[1]
+	if !ok {
+		return frame, &Error{FieldId: 1, Offset: offset, Err: ErrNotFoundInPackager}
+	}
+
+	bitmapFld, fieldIds, length, err := unpackBitmap(bitmapPkg, msgRaw, offset)
+	if err != nil {
+		return frame, err
+	}
+	frame.Fields = append(frame.Fields, bitmapFld)
+	offset += length
+
+	for _, fieldId := range fieldIds {
+		if fieldId == 0 || fieldId == 1 {
+			continue
+		}
+
+		fldPkg, ok := d.Packager.Fields[fieldId]
+		if !ok {
+			return frame, &Error{FieldId: fieldId, Offset: offset, Err: ErrNotFoundInPackager}
+		}
+
+		fld, length, err := unpack(fieldId, fldPkg, msgRaw, offset)
+		if err != nil {
+			return frame, err
+		}
+
+		if subfields, ok := d.Subfields[fieldId]; ok {
+			start := offset + fldPkg.Prefixer().GetPackedLength()
+
+			fld.Subfields, err = unpackSubfields(fld, subfields, msgRaw[:offset+length], start)
+			if err != nil {
+				frame.Fields = append(frame.Fields, fld)
+				return frame, err
+			}
+		}
+
+		frame.Fields = append(frame.Fields, fld)
+		offset += length
+	}
+
+	return frame, nil
+}
+
+// unpack unpacks the field at offset and returns it with the number of bytes consumed
+func unpack(fieldId int, fldPkg field.Packager, raw []byte, offset int) (fld Field, length int, err error) {
+	fld = Field{
+		Id:          fieldId,
+		Description: description(fldPkg),
+		Offset:      offset,
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = &Error{FieldId: fieldId, Description: fld.Description, Offset: offset, Err: fmt.Errorf("%w: %v", ErrIndexOutOfRange, r)}
+		}
+	}()
+
+	// The pattern is disabled so values that do not match are still shown and flagged as invalid
+	value, length, err := validate.PermissiveField(fldPkg).Unpack(raw, offset)
+	if err != nil {
+		return fld, 0, &Error{FieldId: fieldId, Description: fld.Description, Offset: offset, Err: err}
+	}
+
+	fld.Raw = fmt.Sprintf("%X", raw[offset:offset+length])
+	fld.Value = value
+	fld.Invalid = fldPkg.Pattern() != nil && !fldPkg.Pattern().MatchString(value)
+
+	return fld, length, nil
+}
+
+// unpackBitmap unpacks the primary bitmap and the secondary one when bit 1 is set
+func unpackBitmap(fldPkg field.Packager, raw []byte, offset int) (fld Field, fieldIds []int, length int, err error) {
+	fld = Field{
+		Id:          1,
+		Description: description(fldPkg),
+		Offset:      offset,
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = &Error{FieldId: 1, Description: fld.Description, Offset: offset, Err: fmt.Errorf("%w: %v", ErrIndexOutOfRange, r)}
+		}
+	}()
+
+	bMap, length, err := bitmap.Unpack(validate.PermissiveField(fldPkg), raw, offset)
+	if err != nil {
+		return fld, nil, 0, &Error{FieldId: 1, Description: fld.Description, Offset: offset, Err: err}
+	}
+
+	fld.Raw = fmt.Sprintf("%X", raw[offset:offset+length])
+	fld.Value = fld.Raw
+
+	return fld, bMap.GetSliceString(), length, nil
+}
+
+// unpackSubfields unpacks the private bitmap of parent starting at offset and the subfields it flags
+func unpackSubfields(parent Field, subfields subfield.Subfields, raw []byte, offset int) ([]Field, error) {
+	wrap := func(err error) error {
+		if e, ok := err.(*Error); ok {
+			return &Error{
+				FieldId:     parent.Id,
+				Description: parent.Description,
+				Offset:      e.Offset,
+				Err:         fmt.Errorf("subfield %d (%s): %w", e.FieldId, e.Description, e.Err),
+			}
+		}
+		return err
+	}
+
+	bitmapFld, length, err := unpack(0, subfields[0], raw, offset)
+	if err != nil {
+		return nil, wrap(err)
+	}
+	offset += length
+
+	bits, err := hex.DecodeString(bitmapFld.Value)
+	if err != nil {
+		return nil, wrap(&Error{FieldId: 0, Description: bitmapFld.Description, Offset: bitmapFld.Offset, Err: err})
+	}
+
+	result := []Field{bitmapFld}
+	for i := 0; i < len(bits)*8; i++ {
+		if bits[i/8]&(0x80>>(i%8)) == 0 {
+			continue
+		}
+
+		subfieldId := i + 1
+
+		fldPkg, ok := subfields[subfieldId]
+		if !ok {
+			return result, wrap(&Error{FieldId: subfieldId, Offset: offset, Err: ErrNotFoundInPackager})
+		}
+
+		fld, length, err := unpack(subfieldId, fldPkg, raw, offset)
+		if err != nil {
+			return result, wrap(err)
+		}
+
+		result = append(result, fld)
+		offset += length
+	}
+
+	return result, nil
+}
+
+func description(fldPkg field.Packager) string {
+	if fld, ok := fldPkg.(*field.Field); ok {
+		return fld.Description
+	}
+
+	return ""
+}
@@ -0,0 +1,255 @@
+package decode
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/packager"
+	"github.com/tomasdemarco/iso8583/packager/field"
+	"strings"
+	"testing"
+)
+
+// frame is a 0200 of iso87BPackager with DE3, DE11 and DE41:
+// MTI 0200 at 0, bitmap at 2, DE3 at 10, DE11 at 13 and DE41 at 16, 24 bytes in total
+const frame = "0200" + "2020000000800000" + "000000" + "000123" + "3030303030303031"
+
+func loadPackager(t *testing.T, file string) *packager.Packager {
+	t.Helper()
+
+	pkg, err := subfield.LoadFromJson("../iso8583/packager", file)
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode hex: %v", err)
+	}
+
+	return raw
+}
+
+type want struct {
+	id     int
+	offset int
+	raw    string
+	value  string
+}
+
+func assertFields(t *testing.T, fields []Field, wants []want) {
+	t.Helper()
+
+	if len(fields) != len(wants) {
+		t.Fatalf("decoded %d fields %+v, want %d", len(fields), fields, len(wants))
+	}
+
+	for i, w := range wants {
+		fld := fields[i]
+		if fld.Id != w.id || fld.Offset != w.offset || fld.Raw != w.raw || fld.Value != w.value {
+			t.Errorf("field %d at %d is %s %q, want field %d at %d %s %q", fld.Id, fld.Offset, fld.Raw, fld.Value, w.id, w.offset, w.raw, w.value)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	pkg := loadPackager(t, "iso87BPackager.json")
+
+	fields := func(base int) []want {
+		return []want{
+			{0, base, "0200", "0200"},
+			{1, base + 2, "2020000000800000", "2020000000800000"},
+			{3, base + 10, "000000", "000000"},
+			{11, base + 13, "000123", "000123"},
+			{41, base + 16, "3030303030303031", "00000001"},
+		}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		input    string
+		opts     []Option
+		length   int
+		header   string
+		fields   []want
+		trailing string
+	}{
+		{"message", frame, nil, 0, "", fields(0), ""},
+		// the offsets count from the first byte of the input
+		{"framed", "0018" + frame, []Option{WithLengthPrefix(true)}, 24, "", fields(2), ""},
+		{"header", "001D" + "6000010000" + frame, []Option{WithLengthPrefix(true), WithHeaderLength(5)}, 29, "6000010000", fields(7), ""},
+		// the bytes after the message or after the length of the prefix are trailing
+		{"over-long", frame + "FFFF", nil, 0, "", fields(0), "FFFF"},
+		{"over-long framed", "0018" + frame + "FFFF", []Option{WithLengthPrefix(true)}, 24, "", fields(2), "FFFF"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New(pkg, tt.opts...).Decode(decodeHex(t, tt.input))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			if result.Length != tt.length || result.Header != tt.header || result.Trailing != tt.trailing {
+				t.Errorf("length %d, header %q, trailing %q", result.Length, result.Header, result.Trailing)
+			}
+
+			assertFields(t, result.Fields, tt.fields)
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	pkg := loadPackager(t, "iso87BPackager.json")
+
+	for _, tt := range []struct {
+		name  string
+		input string
+		opts  []Option
+		// err is the sentinel of the error, nil for the errors of the library
+		err     error
+		fieldId int
+		offset  int
+		// number of fields decoded before the error
+		fields int
+	}{
+		{"truncated DE41", frame[:40], nil, nil, 41, 16, 4},
+		{"truncated bitmap", frame[:12], nil, nil, 1, 2, 1},
+		{"truncated MTI", frame[:2], nil, nil, 0, 0, 0},
+		// bit 5 is set and DE5 is not in the packager
+		{"field not in packager", "0200" + "2820000000800000" + "000000", nil, ErrNotFoundInPackager, 5, 13, 3},
+		{"truncated framed", "0018" + frame[:40], []Option{WithLengthPrefix(true)}, ErrInvalidLength, -1, 0, 0},
+		{"truncated prefix", "00", []Option{WithLengthPrefix(true)}, ErrInvalidLength, -1, 0, 0},
+		{"truncated header", "0003" + "600001", []Option{WithLengthPrefix(true), WithHeaderLength(5)}, ErrInvalidHeader, -1, 0, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New(pkg, tt.opts...).Decode(decodeHex(t, tt.input))
+			if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("decode returned %v, want %v", err, tt.err)
+			}
+
+			if len(result.Fields) != tt.fields {
+				t.Errorf("decoded %d fields before the error, want %d", len(result.Fields), tt.fields)
+			}
+
+			if tt.fieldId < 0 {
+				return
+			}
+
+			var e *Error
+			if !errors.As(err, &e) || e.FieldId != tt.fieldId || e.Offset != tt.offset {
+				t.Errorf("error %v, want field %d at offset %d", err, tt.fieldId, tt.offset)
+			}
+		})
+	}
+}
+
+// slicingField unpacks without checking the length of the input, as a field packager
+// that panics on a truncated frame
+type slicingField struct {
+	field.Packager
+}
+
+func (f slicingField) Unpack(raw []byte, offset int) (string, int, error) {
+	return string(raw[offset : offset+f.Length()]), f.Length(), nil
+}
+
+func TestDecodePanic(t *testing.T) {
+	pkg := loadPackager(t, "iso87BPackager.json")
+	pkg.Fields[41] = slicingField{pkg.Fields[41]}
+
+	decoder := New(pkg)
+
+	result, err := decoder.Decode(decodeHex(t, frame))
+	if err != nil || result.Fields[4].Value != "00000001" {
+		t.Fatalf("decode returned %v, %+v", err, result.Fields)
+	}
+
+	// the panic is recovered and reported as the error of the field
+	result, err = decoder.Decode(decodeHex(t, frame[:40]))
+
+	var e *Error
+	if !errors.Is(err, ErrIndexOutOfRange) || !errors.As(err, &e) || e.FieldId != 41 || e.Offset != 16 {
+		t.Errorf("decode of the truncated frame returned %v, want field 41 at offset 16", err)
+	}
+
+	assertFields(t, result.Fields[:4], []want{
+		{0, 0, "0200", "0200"},
+		{1, 2, "2020000000800000", "2020000000800000"},
+		{3, 10, "000000", "000000"},
+		{11, 13, "000123", "000123"},
+	})
+}
+
+func TestDecodeSubfields(t *testing.T) {
+	pkg := loadPackager(t, "iso87BVisaBase1Packager.json")
+
+	msg := gopostest.NewMessage(pkg, map[int]string{0: "0110", 11: "000123", 39: "00"})
+	err := subfield.Set(msg, 62, 1, "Y ")
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	err = subfield.Set(msg, 62, 2, "0123456789012345")
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	raw, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+
+	decoder := New(pkg)
+	for fieldId, defs := range subfield.Definitions(pkg) {
+		decoder.Subfields[fieldId] = defs
+	}
+
+	result, err := decoder.Decode(raw)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	de62 := result.Fields[len(result.Fields)-1]
+	if de62.Id != 62 || len(de62.Subfields) != 3 {
+		t.Fatalf("field %d with subfields %+v, want DE62 with its bitmap and 2 subfields", de62.Id, de62.Subfields)
+	}
+
+	// the subfields start after the length prefix of the field
+	if de62.Subfields[0].Offset != de62.Offset+1 || de62.Subfields[1].Value != "Y " || de62.Subfields[2].Value != "0123456789012345" {
+		t.Errorf("subfields %+v", de62.Subfields)
+	}
+
+	// a field cut inside a subfield, with its length prefix shortened to match, reports the field and the subfield
+	cut := raw[:len(raw)-2]
+	cut[de62.Offset] -= 2
+
+	_, err = decoder.Decode(cut)
+	var e *Error
+	if !errors.As(err, &e) || e.FieldId != 62 || !strings.Contains(err.Error(), "subfield 2") {
+		t.Errorf("decode of the truncated subfield returned %v", err)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	result, err := New(loadPackager(t, "iso87BPackager.json")).Decode(decodeHex(t, frame+"FF"))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	var out strings.Builder
+	err = result.WriteTable(&out)
+	if err != nil {
+		t.Fatalf("write table: %v", err)
+	}
+
+	for _, row := range []string{"41        16      Card Acceptor Terminal Identification", "3030303030303031  00000001", "TRAILING  FF"} {
+		if !strings.Contains(out.String(), row) {
+			t.Errorf("table does not contain %q:\n%s", row, out.String())
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package decode
+
+import "errors"
+
+var (
+	ErrInvalidLength      = errors.New("invalid length prefix")
+	ErrInvalidHeader      = errors.New("invalid header")
+	ErrIndexOutOfRange    = errors.New("index out of range")
+	ErrNotFoundInPackager = errors.New("not found in packager")
+)
@@ -0,0 +1,105 @@
+package decode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Frame is a message decoded field by field. Offsets count from the first byte of the input,
+// so they point into the hex dump as it was received.
+type Frame struct {
+	Length   int     `json:"length,omitempty"`
+	Header   string  `json:"header,omitempty"`
+	Fields   []Field `json:"fields"`
+	Trailing string  `json:"trailing,omitempty"`
+}
+
+// Field is a decoded field: its raw bytes in hex, including the length prefix, and its value.
+// Invalid reports that the value does not match the pattern of the packager.
+type Field struct {
+	Id          int     `json:"id"`
+	Description string  `json:"description"`
+	Offset      int     `json:"offset"`
+	Raw         string  `json:"raw"`
+	Value       string  `json:"value"`
+	Invalid     bool    `json:"invalid,omitempty"`
+	Subfields   []Field `json:"subfields,omitempty"`
+}
+
+// Values returns the value of each field by id
+func (f *Frame) Values() map[int]string {
+	fields := make(map[int]string, len(f.Fields))
+	for _, fld := range f.Fields {
+		fields[fld.Id] = fld.Value
+	}
+
+	return fields
+}
+
+// Error is the failure to decode a field, with the offset where the field starts
+type Error struct {
+	FieldId     int
+	Description string
+	Offset      int
+	Err         error
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("field %d at offset %d: %v", e.FieldId, e.Offset, e.Err)
+	}
+
+	return fmt.Sprintf("field %d (%s) at offset %d: %v", e.FieldId, e.Description, e.Offset, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WriteTable writes the fields as a table of field number, offset, description, raw bytes and value.
+// Long raw bytes are cut, the JSON encoding of the frame keeps them whole.
+// Subfields are numbered as field.subfield and values that do not match the packager are marked with '!'.
+func (f *Frame) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if f.Length > 0 {
+		_, _ = fmt.Fprintf(tw, "LENGTH\t%d\n", f.Length)
+	}
+	if f.Header != "" {
+		_, _ = fmt.Fprintf(tw, "HEADER\t%s\n", f.Header)
+	}
+
+	_, _ = fmt.Fprintln(tw, "FIELD\tOFFSET\tDESCRIPTION\tRAW\tVALUE")
+	writeRows(tw, "", f.Fields)
+
+	if f.Trailing != "" {
+		_, _ = fmt.Fprintf(tw, "TRAILING\t%s\n", f.Trailing)
+	}
+
+	return tw.Flush()
+}
+
+// maxRawWidth is the number of hex digits of the raw bytes shown in a table row
+const maxRawWidth = 48
+
+func writeRows(w io.Writer, parent string, fields []Field) {
+	for _, fld := range fields {
+		id := parent + strconv.Itoa(fld.Id)
+
+		value := fld.Value
+		if fld.Invalid {
+			value += " !"
+		}
+
+		raw := fld.Raw
+		if len(raw) > maxRawWidth {
+			raw = raw[:maxRawWidth] + "..."
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", id, fld.Offset, fld.Description, raw, value)
+
+		writeRows(w, id+".", fld.Subfields)
+	}
+}
@@ -0,0 +1,11 @@
+package emv
+
+import "errors"
+
+var (
+	ErrInvalidTlv    = errors.New("invalid TLV data")
+	ErrInvalidTag    = errors.New("invalid tag")
+	ErrValueTooLong  = errors.New("value longer than 65535 bytes")
+	ErrMissingTags   = errors.New("missing mandatory tags")
+	ErrFieldNotFound = errors.New("field not found in message")
+)
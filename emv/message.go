@@ -0,0 +1,107 @@
+package emv
+
+import (
+	"fmt"
+	"github.com/tomasdemarco/iso8583/message"
+	"strings"
+)
+
+// Field is the field of the ICC data in ISO 8583 messages
+const Field = 55
+
+// CryptogramTags are the tags an online authorization request needs to verify the ARQC
+var CryptogramTags = []string{"9F26", "9F27", "9F10", "9F37", "9F36", "95", "9A", "9C", "9F02", "5F2A", "82", "9F1A"}
+
+// FromMessage returns the TLVs of DE55 of msg
+func FromMessage(msg *message.Message) (List, error) {
+	value, err := msg.GetField(Field)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrFieldNotFound, Field)
+	}
+
+	return ParseHex(value)
+}
+
+// SetMessage sets DE55 of msg with the TLVs of l
+func SetMessage(msg *message.Message, l List) error {
+	value, err := l.Hex()
+	if err != nil {
+		return err
+	}
+
+	msg.SetField(Field, value)
+	return nil
+}
+
+// Validate returns ErrMissingTags with the cryptogram tags that are not in l
+func Validate(l List) error {
+	missing := l.Missing(CryptogramTags...)
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingTags, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// IssuerScript is an issuer script template, 71 to run before the second GENERATE AC
+// and 72 after it, with the script identifier (9F18) and the commands (86)
+type IssuerScript struct {
+	Tag      string
+	Id       []byte
+	Commands [][]byte
+}
+
+// Tlv returns the script as a constructed TLV
+func (s IssuerScript) Tlv() (Tlv, error) {
+	var children List
+	if len(s.Id) > 0 {
+		children = append(children, Tlv{Tag: "9F18", Value: s.Id})
+	}
+
+	for _, command := range s.Commands {
+		children = append(children, Tlv{Tag: "86", Value: command})
+	}
+
+	value, err := children.Bytes()
+	if err != nil {
+		return Tlv{}, err
+	}
+
+	return Tlv{Tag: strings.ToUpper(s.Tag), Value: value}, nil
+}
+
+// ResponseData returns the DE55 of an authorization response: the issuer authentication
+// data (91), when it is not empty, followed by the issuer scripts
+func ResponseData(issuerAuthData []byte, scripts ...IssuerScript) (List, error) {
+	var l List
+	if len(issuerAuthData) > 0 {
+		l = append(l, Tlv{Tag: "91", Value: issuerAuthData})
+	}
+
+	for _, script := range scripts {
+		t, err := script.Tlv()
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, t)
+	}
+
+	return l, nil
+}
+
+// LogTag is a TLV as it is written in the logs
+type LogTag struct {
+	Tag   string `json:"tag"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value"`
+}
+
+// Log returns the TLVs with the names of the Dictionary and the values in hex
+func (l List) Log() []LogTag {
+	tags := make([]LogTag, len(l))
+	for i, t := range l {
+		tags[i] = LogTag{Tag: t.Tag, Name: t.Name(), Value: fmt.Sprintf("%X", t.Value)}
+	}
+
+	return tags
+}
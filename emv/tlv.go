@@ -0,0 +1,200 @@
+// Package emv reads and builds the BER-TLV data of the EMV chip cards, carried in DE55.
+//
+//	data, err := emv.FromMessage(request)
+//	cryptogram, ok := data.Get("9F26")
+//
+//	response := emv.List{}.Set("91", issuerAuthData)
+//	emv.SetMessage(msg, response)
+package emv
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Tlv is a tag with its value, Tag is in uppercase hex, e.g. "9F26"
+type Tlv struct {
+	Tag   string
+	Value []byte
+}
+
+// Constructed reports whether the value of the tag is a list of TLVs, e.g. the issuer scripts 71 and 72
+func (t Tlv) Constructed() bool {
+	b, err := hex.DecodeString(t.Tag)
+	return err == nil && len(b) > 0 && b[0]&0x20 == 0x20
+}
+
+// Children parses the value of a constructed tag
+func (t Tlv) Children() (List, error) {
+	return Parse(t.Value)
+}
+
+// Name returns the name of the tag in the Dictionary, or "" when it is unknown
+func (t Tlv) Name() string {
+	return Dictionary[t.Tag]
+}
+
+// Bytes returns the tag, length and value encoded
+func (t Tlv) Bytes() ([]byte, error) {
+	tag, err := hex.DecodeString(t.Tag)
+	if err != nil || len(tag) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTag, t.Tag)
+	}
+
+	length := len(t.Value)
+
+	b := make([]byte, 0, len(tag)+3+length)
+	b = append(b, tag...)
+	switch {
+	case length < 0x80:
+		b = append(b, byte(length))
+	case length <= 0xFF:
+		b = append(b, 0x81, byte(length))
+	case length <= 0xFFFF:
+		b = append(b, 0x82, byte(length>>8), byte(length))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrValueTooLong, t.Tag)
+	}
+
+	return append(b, t.Value...), nil
+}
+
+// List is the TLV data of a field, in the order of the field
+type List []Tlv
+
+// Parse returns the TLVs of data
+func Parse(data []byte) (List, error) {
+	var list List
+
+	position := 0
+	for position < len(data) {
+		tagLength := 1
+		if data[position]&0x1F == 0x1F {
+			for position+tagLength < len(data) && data[position+tagLength]&0x80 == 0x80 {
+				tagLength++
+			}
+			tagLength++
+		}
+
+		if position+tagLength >= len(data) {
+			return nil, fmt.Errorf("%w: tag without length at %d", ErrInvalidTlv, position)
+		}
+
+		tag := fmt.Sprintf("%X", data[position:position+tagLength])
+		position += tagLength
+
+		valueLength := int(data[position])
+		position++
+		if valueLength&0x80 == 0x80 {
+			n := valueLength & 0x7F
+			if n == 0 || n > 2 || position+n > len(data) {
+				return nil, fmt.Errorf("%w: invalid length of tag %s", ErrInvalidTlv, tag)
+			}
+
+			valueLength = 0
+			for _, b := range data[position : position+n] {
+				valueLength = valueLength<<8 | int(b)
+			}
+			position += n
+		}
+
+		if position+valueLength > len(data) {
+			return nil, fmt.Errorf("%w: value of tag %s longer than the data", ErrInvalidTlv, tag)
+		}
+
+		list = append(list, Tlv{Tag: tag, Value: data[position : position+valueLength]})
+		position += valueLength
+	}
+
+	return list, nil
+}
+
+// ParseHex returns the TLVs of the hex string value, the format of DE55 in a message
+func ParseHex(value string) (List, error) {
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTlv, err)
+	}
+
+	return Parse(data)
+}
+
+// Get returns the first TLV of tag
+func (l List) Get(tag string) (Tlv, bool) {
+	tag = strings.ToUpper(tag)
+	for _, t := range l {
+		if t.Tag == tag {
+			return t, true
+		}
+	}
+
+	return Tlv{}, false
+}
+
+// Set returns the list with the value of tag replaced, or appended when the tag is not in the list
+func (l List) Set(tag string, value []byte) List {
+	tag = strings.ToUpper(tag)
+	for i, t := range l {
+		if t.Tag == tag {
+			result := append(List(nil), l...)
+			result[i].Value = value
+			return result
+		}
+	}
+
+	return append(append(List(nil), l...), Tlv{Tag: tag, Value: value})
+}
+
+// Remove returns the list without tags
+func (l List) Remove(tags ...string) List {
+	remove := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		remove[strings.ToUpper(tag)] = true
+	}
+
+	var result List
+	for _, t := range l {
+		if !remove[t.Tag] {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// Missing returns the tags that are not in the list
+func (l List) Missing(tags ...string) []string {
+	var missing []string
+	for _, tag := range tags {
+		if _, ok := l.Get(tag); !ok {
+			missing = append(missing, strings.ToUpper(tag))
+		}
+	}
+
+	return missing
+}
+
+// Bytes returns the TLVs encoded
+func (l List) Bytes() ([]byte, error) {
+	var data []byte
+	for _, t := range l {
+		b, err := t.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+
+	return data, nil
+}
+
+// Hex returns the TLVs encoded as an uppercase hex string
+func (l List) Hex() (string, error) {
+	data, err := l.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%X", data), nil
+}
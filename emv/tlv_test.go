@@ -0,0 +1,152 @@
+package emv
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseHex(t *testing.T) {
+	// 9F26 cryptogram, 82 AIP, 5F2A currency and 9F10 issuer application data
+	data := "9F2608" + "0123456789ABCDEF" + "82025C00" + "5F2A020032" + "9F1007" + "06010A03A0A800"
+
+	l, err := ParseHex(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tags := []string{"9F26", "82", "5F2A", "9F10"}
+	if len(l) != len(tags) {
+		t.Fatalf("parsed %d tags, want %d", len(l), len(tags))
+	}
+
+	for i, tag := range tags {
+		if l[i].Tag != tag {
+			t.Errorf("tag %d is %s, want %s", i, l[i].Tag, tag)
+		}
+	}
+
+	if cryptogram, ok := l.Get("9f26"); !ok || !bytes.Equal(cryptogram.Value, []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF}) {
+		t.Errorf("cryptogram %X", cryptogram.Value)
+	}
+
+	if got, err := l.Hex(); err != nil || got != data {
+		t.Errorf("hex %s, %v, want %s", got, err, data)
+	}
+}
+
+func TestParseLongForm(t *testing.T) {
+	value := bytes.Repeat([]byte{0xAA}, 200)
+	long := bytes.Repeat([]byte{0xBB}, 300)
+
+	data, err := List{{Tag: "86", Value: value}, {Tag: "72", Value: long}}.Bytes()
+	if err != nil {
+		t.Fatalf("bytes: %v", err)
+	}
+
+	if !bytes.HasPrefix(data, []byte{0x86, 0x81, 200}) || !bytes.Contains(data, []byte{0x72, 0x82, 0x01, 0x2C}) {
+		t.Errorf("lengths are not in the long form: %X", data[:6])
+	}
+
+	l, err := Parse(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if len(l) != 2 || !bytes.Equal(l[0].Value, value) || !bytes.Equal(l[1].Value, long) {
+		t.Errorf("parsed %d tags", len(l))
+	}
+
+	_, err = Tlv{Tag: "86", Value: make([]byte, 0x10000)}.Bytes()
+	if !errors.Is(err, ErrValueTooLong) {
+		t.Errorf("bytes returned %v, want %v", err, ErrValueTooLong)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"tag without length", "9F26"},
+		{"subsequent tag byte without length", "9F"},
+		{"value longer than the data", "9F260801"},
+		{"indefinite length", "9F2680"},
+		{"three bytes length", "9F2683000001AA"},
+		{"long length without bytes", "9F2681"},
+		{"not hex", "9F2G"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseHex(tt.data)
+			if !errors.Is(err, ErrInvalidTlv) {
+				t.Errorf("parse %s returned %v, want %v", tt.data, err, ErrInvalidTlv)
+			}
+		})
+	}
+
+	_, err := Tlv{Tag: "9G"}.Bytes()
+	if !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("bytes returned %v, want %v", err, ErrInvalidTag)
+	}
+}
+
+func TestConstructed(t *testing.T) {
+	script := IssuerScript{Tag: "72", Id: []byte{0x00, 0x00, 0x00, 0x01}, Commands: [][]byte{{0x84, 0x24, 0x00, 0x00}}}
+
+	l, err := ResponseData([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x00, 0x12}, script)
+	if err != nil {
+		t.Fatalf("response data: %v", err)
+	}
+
+	data, err := l.Hex()
+	if err != nil {
+		t.Fatalf("hex: %v", err)
+	}
+
+	l, err = ParseHex(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if l[0].Tag != "91" || l[0].Constructed() {
+		t.Errorf("issuer authentication data %+v", l[0])
+	}
+
+	if !l[1].Constructed() {
+		t.Fatalf("the issuer script is not constructed")
+	}
+
+	children, err := l[1].Children()
+	if err != nil || len(children) != 2 || children[0].Tag != "9F18" || children[1].Tag != "86" {
+		t.Errorf("children %+v, %v", children, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	l := List{{Tag: "9F26", Value: []byte{0x01}}, {Tag: "95", Value: []byte{0x00}}}
+
+	set := l.Set("95", []byte{0x80}).Set("9f36", []byte{0x00, 0x01})
+	if v, _ := set.Get("95"); v.Value[0] != 0x80 || len(set) != 3 {
+		t.Errorf("set %+v", set)
+	}
+
+	if v, _ := l.Get("95"); v.Value[0] != 0x00 {
+		t.Errorf("set modified the list")
+	}
+
+	if removed := set.Remove("9f26", "95"); len(removed) != 1 || removed[0].Tag != "9F36" {
+		t.Errorf("remove %+v", removed)
+	}
+
+	err := Validate(l)
+	if !errors.Is(err, ErrMissingTags) || strings.Contains(err.Error(), "9F26") || !strings.Contains(err.Error(), "9F10") {
+		t.Errorf("validate returned %v", err)
+	}
+
+	if logged := l.Log(); logged[0].Name == "" || logged[0].Value != "01" {
+		t.Errorf("log %+v", logged)
+	}
+}
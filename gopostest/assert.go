@@ -0,0 +1,99 @@
+package gopostest
+
+import (
+	"errors"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+// NewMessage returns a message of pkg with fields
+func NewMessage(pkg *packager.Packager, fields map[int]string) *message.Message {
+	msg := message.NewMessage(pkg)
+	for fieldId, value := range fields {
+		msg.SetField(fieldId, value)
+	}
+
+	return msg
+}
+
+// AssertFields fails the test when a field of msg is absent or differs from fields
+func AssertFields(t testing.TB, msg *message.Message, fields map[int]string) {
+	t.Helper()
+
+	if msg == nil {
+		t.Errorf("gopostest: message is nil")
+		return
+	}
+
+	fieldIds := make([]int, 0, len(fields))
+	for fieldId := range fields {
+		fieldIds = append(fieldIds, fieldId)
+	}
+	sort.Ints(fieldIds)
+
+	for _, fieldId := range fieldIds {
+		value, ok := msg.Fields[fieldId]
+		if !ok {
+			t.Errorf("gopostest: field %d is absent, want %q", fieldId, fields[fieldId])
+			continue
+		}
+
+		if value != fields[fieldId] {
+			t.Errorf("gopostest: field %d is %q, want %q", fieldId, value, fields[fieldId])
+		}
+	}
+}
+
+// AssertResponseCode fails the test when the response code (DE39) of msg is not responseCode
+func AssertResponseCode(t testing.TB, msg *message.Message, responseCode string) {
+	t.Helper()
+
+	AssertFields(t, msg, map[int]string{39: responseCode})
+}
+
+// AssertNoField fails the test when msg has any of fieldIds
+func AssertNoField(t testing.TB, msg *message.Message, fieldIds ...int) {
+	t.Helper()
+
+	if msg == nil {
+		t.Errorf("gopostest: message is nil")
+		return
+	}
+
+	for _, fieldId := range fieldIds {
+		if value, ok := msg.Fields[fieldId]; ok {
+			t.Errorf("gopostest: field %d is %q, want it absent", fieldId, value)
+		}
+	}
+}
+
+// AssertClosed fails the test when the peer of conn does not close it within timeout.
+// Bytes written by the peer before closing are ignored.
+func AssertClosed(t testing.TB, conn net.Conn, timeout time.Duration) {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	buf := make([]byte, 512)
+	for {
+		_, err := conn.Read(buf)
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("gopostest: connection still open after %s", timeout)
+		} else if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) && !errors.Is(err, net.ErrClosed) {
+			t.Errorf("gopostest: read: %v", err)
+		}
+
+		_ = conn.Close()
+		return
+	}
+}
@@ -0,0 +1,104 @@
+package gopostest
+
+import (
+	"errors"
+	"github.com/tomasdemarco/iso8583/message"
+	"io"
+	"net"
+)
+
+// Fault is a framing error written by SendFault
+type Fault int
+
+const (
+	// LengthTooLong announces more bytes than the message has
+	LengthTooLong Fault = iota
+	// LengthTooShort announces fewer bytes than the message has
+	LengthTooShort
+	// Oversized announces a length over the maximum message size of the server
+	Oversized
+	// Garbage writes bytes that are not a message after a valid length
+	Garbage
+	// Truncated writes the length and half of the message, then closes the connection
+	Truncated
+)
+
+func (f Fault) String() string {
+	switch f {
+	case LengthTooLong:
+		return "length too long"
+	case LengthTooShort:
+		return "length too short"
+	case Oversized:
+		return "oversized"
+	case Garbage:
+		return "garbage"
+	case Truncated:
+		return "truncated"
+	default:
+		return "unknown"
+	}
+}
+
+// SendFault opens a connection to the server and writes msg framed with fault.
+// The connection is returned so the test can check how the server reacted, e.g. with AssertClosed.
+func (h *Harness) SendFault(fault Fault, msg *message.Message) net.Conn {
+	h.T.Helper()
+
+	frame := h.Frame(msg)
+	lengthRaw, err := h.Client.LengthPackFunc(h.Packager.Prefix, len(frame))
+	if err != nil {
+		h.T.Fatalf("gopostest: pack length: %v", err)
+	}
+	prefixLength := len(lengthRaw)
+	body := frame[prefixLength:]
+
+	var raw []byte
+	switch fault {
+	case LengthTooLong:
+		raw = h.withLength(len(body)+16, body)
+	case LengthTooShort:
+		raw = h.withLength(len(body)/2, body)
+	case Oversized:
+		raw = h.withLength(h.Server.MaxMessageSize+1, body)
+	case Garbage:
+		garbage := make([]byte, len(body))
+		for i := range garbage {
+			garbage[i] = 0xFF
+		}
+		raw = h.withLength(len(garbage), garbage)
+	case Truncated:
+		raw = frame[:prefixLength+len(body)/2]
+	default:
+		h.T.Fatalf("gopostest: unknown fault %d", fault)
+	}
+
+	conn := h.Dial()
+
+	h.goroutine(func() {
+		go func() {
+			_, err := conn.Write(raw)
+			if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+				h.T.Errorf("gopostest: write %s: %v", fault, err)
+			}
+
+			if fault == Truncated {
+				_ = conn.Close()
+			}
+		}()
+	})
+
+	return conn
+}
+
+// withLength returns body after a length prefix of length
+func (h *Harness) withLength(length int, body []byte) []byte {
+	h.T.Helper()
+
+	lengthRaw, err := h.Client.LengthPackFunc(h.Packager.Prefix, length)
+	if err != nil {
+		h.T.Fatalf("gopostest: pack length: %v", err)
+	}
+
+	return append(lengthRaw, body...)
+}
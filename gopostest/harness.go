@@ -0,0 +1,307 @@
+// Package gopostest runs a server.Server and a client.Client connected in memory, so the
+// handlers of a server can be tested without opening ports.
+//
+//	h := gopostest.New(t, pkg, sim.Handle)
+//	response, err := h.Do(h.Message(map[int]string{0: "0200", 3: "000000", 4: "000000001000"}))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	gopostest.AssertResponseCode(t, response, "00")
+package gopostest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/client"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/header"
+	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"io"
+	"net"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Harness is a server and a client connected through an in-memory Listener
+type Harness struct {
+	T        testing.TB
+	Server   *server.Server
+	Client   *client.Client
+	Listener *Listener
+	Packager *packager.Packager
+	Clock    *Clock
+
+	serverOpts []server.Option
+	clientOpts []client.ClientOption
+	header     []byte
+	timeout    time.Duration
+	logOutput  io.Writer
+
+	labels pprof.LabelSet
+	label  string
+	served chan struct{}
+	once   sync.Once
+}
+
+// harnesses numbers the harnesses, to tell the goroutines of each one apart
+var harnesses atomic.Int64
+
+type Option func(*Harness)
+
+func WithServerOptions(opts ...server.Option) Option {
+	return func(h *Harness) {
+		h.serverOpts = append(h.serverOpts, opts...)
+	}
+}
+
+func WithClientOptions(opts ...client.ClientOption) Option {
+	return func(h *Harness) {
+		h.clientOpts = append(h.clientOpts, opts...)
+	}
+}
+
+// WithHeader sets a fixed header of the messages on both sides
+func WithHeader(value []byte) Option {
+	return func(h *Harness) {
+		h.header = value
+	}
+}
+
+func WithClock(clock *Clock) Option {
+	return func(h *Harness) {
+		h.Clock = clock
+	}
+}
+
+// WithTimeout sets the time the client waits for a response and the server waits
+// for the rest of a message once its length was read
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Harness) {
+		h.timeout = timeout
+	}
+}
+
+// WithLogOutput writes the logs of the server and the client to w, they are discarded by default
+func WithLogOutput(w io.Writer) Option {
+	return func(h *Harness) {
+		h.logOutput = w
+	}
+}
+
+// New starts a server with handler and connects a client to it.
+// The harness is closed by the cleanup of t.
+func New(t testing.TB, pkg *packager.Packager, handler server.HandlerFunc, opts ...Option) *Harness {
+	t.Helper()
+
+	h := Harness{
+		T:         t,
+		Listener:  NewListener(),
+		Packager:  pkg,
+		Clock:     NewClock(time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)),
+		timeout:   2 * time.Second,
+		logOutput: io.Discard,
+		served:    make(chan struct{}),
+	}
+
+	h.label = strconv.FormatInt(harnesses.Add(1), 10)
+	h.labels = pprof.Labels("gopostest", h.label)
+
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	serverOpts := []server.Option{
+		server.WithLogger(logger.New(logger.Debug, "server", logger.WithWriter(h.logOutput))),
+		server.WithReadMessageTimeout(h.timeout),
+	}
+	h.Server = server.New(0, pkg, handler, append(serverOpts, h.serverOpts...)...)
+
+	clientOpts := []client.ClientOption{
+		client.WithLogger(logger.New(logger.Debug, "client", logger.WithWriter(h.logOutput))),
+		client.WithDialer(h.Listener.Dial),
+		client.WithAutoReconnect(false),
+		client.WithMatchFields([]int{7, 11}),
+		client.WithTimeout(h.timeout),
+		client.WithStampPolicy(&client.StampPolicy{
+			Fields:   []int{7, 11, 12, 13},
+			Now:      h.Clock.Now,
+			Location: time.UTC,
+			RrnFunc:  client.Rrn,
+		}),
+	}
+	h.Client = client.New("pipe", 0, pkg, append(clientOpts, h.clientOpts...)...)
+
+	if h.header != nil {
+		h.Server.HeaderPackFunc, h.Server.HeaderUnpackFunc = header.Fixed(h.header)
+		h.Client.HeaderPackFunc, h.Client.HeaderUnpackFunc = header.Fixed(h.header)
+	}
+
+	var err error
+	h.goroutine(func() {
+		go func() {
+			defer close(h.served)
+			_ = h.Server.Serve(h.Listener)
+		}()
+
+		err = h.Client.Connect()
+	})
+
+	t.Cleanup(h.Close)
+
+	if err != nil {
+		t.Fatalf("gopostest: connect: %v", err)
+	}
+
+	return &h
+}
+
+// Message returns a message with fields, plus the trace fields of the stamp policy of the client
+// (DE7, DE11, DE12 and DE13 from the clock by default) when they are absent
+func (h *Harness) Message(fields map[int]string) *message.Message {
+	h.T.Helper()
+
+	msg := NewMessage(h.Packager, fields)
+
+	if h.Client.StampPolicy != nil {
+		err := h.Client.StampPolicy.Stamp(msg, h.Client.Stan)
+		if err != nil {
+			h.T.Fatalf("gopostest: stamp: %v", err)
+		}
+	}
+
+	return msg
+}
+
+// Do sends msg with the client and waits for the response
+func (h *Harness) Do(msg *message.Message) (*message.Message, error) {
+	var response *message.Message
+	var err error
+	h.goroutine(func() {
+		response, err = h.Client.Do(ctx.NewRequestContext(nil, msg), msg)
+	})
+
+	return response, err
+}
+
+// Dial opens another connection to the server, to write raw bytes to it
+func (h *Harness) Dial() net.Conn {
+	h.T.Helper()
+
+	conn, err := h.Listener.Dial("pipe", "pipe")
+	if err != nil {
+		h.T.Fatalf("gopostest: dial: %v", err)
+	}
+
+	return conn
+}
+
+// Frame returns msg as it is written to the connection: length, header, message and trailer
+func (h *Harness) Frame(msg *message.Message) []byte {
+	h.T.Helper()
+
+	raw, err := msg.Pack()
+	if err != nil {
+		h.T.Fatalf("gopostest: pack: %v", err)
+	}
+
+	headerRaw, headerLength, err := h.Client.HeaderPackFunc(msg.Header)
+	if err != nil {
+		h.T.Fatalf("gopostest: pack header: %v", err)
+	}
+
+	trailerRaw, trailerLength, err := h.Client.TrailerPackFunc(msg.Trailer)
+	if err != nil {
+		h.T.Fatalf("gopostest: pack trailer: %v", err)
+	}
+
+	lengthRaw, err := h.Client.LengthPackFunc(h.Packager.Prefix, len(raw)+headerLength+trailerLength)
+	if err != nil {
+		h.T.Fatalf("gopostest: pack length: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(lengthRaw)
+	buf.Write(headerRaw)
+	buf.Write(raw)
+	buf.Write(trailerRaw)
+
+	return buf.Bytes()
+}
+
+// Close disconnects the client, closes the server and fails the test when goroutines
+// started by the harness are still running. It is safe to call more than once.
+func (h *Harness) Close() {
+	h.once.Do(func() {
+		_ = h.Client.Disconnect()
+
+		err := h.Server.Close()
+		if err != nil {
+			h.T.Errorf("gopostest: close server: %v", err)
+		}
+
+		<-h.served
+
+		h.checkGoroutines()
+	})
+}
+
+// goroutine runs f with the labels of the harness, so the goroutines that f starts, and the ones
+// they start in turn, are told apart from the goroutines of the test and of other harnesses
+func (h *Harness) goroutine(f func()) {
+	pprof.Do(context.Background(), h.labels, func(context.Context) {
+		f()
+	})
+}
+
+// checkGoroutines waits up to a second for the goroutines of the harness to end
+func (h *Harness) checkGoroutines() {
+	deadline := time.Now().Add(time.Second)
+	for {
+		count, stacks := h.goroutines()
+		if count == 0 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			h.T.Errorf("gopostest: %d goroutines leaked after close:\n%s", count, stacks)
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// goroutines returns the number and the stacks of the running goroutines of the harness,
+// from the goroutine profile grouped by stack and labels
+func (h *Harness) goroutines() (int, string) {
+	buf := new(bytes.Buffer)
+	_ = pprof.Lookup("goroutine").WriteTo(buf, 1)
+
+	label := fmt.Sprintf("# labels: {%q:%q}", "gopostest", h.label)
+
+	var count int
+	var stacks strings.Builder
+	for _, group := range strings.Split(buf.String(), "\n\n") {
+		if !strings.Contains(group, label) {
+			continue
+		}
+
+		// each group starts with "<count> @ <pcs>"
+		n, _ := strconv.Atoi(strings.SplitN(group, " ", 2)[0])
+		count += n
+
+		stacks.WriteString(group)
+		stacks.WriteString("\n\n")
+	}
+
+	return count, stacks.String()
+}
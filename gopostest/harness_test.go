@@ -0,0 +1,147 @@
+package gopostest
+
+import (
+	"fmt"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/packager"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func loadPackager(t *testing.T) *packager.Packager {
+	t.Helper()
+
+	pkg, err := subfield.LoadFromJson("../iso8583/packager", "iso87BPackager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+// approve answers every request with response code 00
+func approve(c *ctx.RequestContext, srv *server.Server) {
+	response, err := srv.NewResponse(c.Request)
+	if err != nil {
+		return
+	}
+
+	response.SetField(39, "00")
+	_ = srv.SendResponse(c, response)
+}
+
+// recorder keeps the errors reported through it instead of failing the test
+type recorder struct {
+	testing.TB
+
+	mu     sync.Mutex
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) reported() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.errors...)
+}
+
+// request returns the fields of an approved purchase
+func request() map[int]string {
+	return map[int]string{0: "0200", 3: "000000", 4: "000000001000", 41: "00000001"}
+}
+
+func TestHarness(t *testing.T) {
+	h := New(t, loadPackager(t), approve)
+
+	for i := 0; i < 5; i++ {
+		msg := h.Message(request())
+
+		response, err := h.Do(msg)
+		if err != nil {
+			t.Fatalf("do: %v", err)
+		}
+
+		AssertResponseCode(t, response, "00")
+		AssertFields(t, response, map[int]string{0: "0210", 11: msg.Fields[11], 41: "00000001"})
+	}
+}
+
+func TestHarnessStamp(t *testing.T) {
+	clock := NewClock(time.Date(2024, time.March, 5, 14, 30, 15, 0, time.UTC))
+	h := New(t, loadPackager(t), approve, WithClock(clock))
+
+	AssertFields(t, h.Message(request()), map[int]string{7: "0305143015", 12: "143015", 13: "0305"})
+
+	clock.Advance(time.Hour)
+	AssertFields(t, h.Message(request()), map[int]string{7: "0305153015", 12: "153015"})
+}
+
+// TestSendFault checks that the server closes the connections with framing errors. The client of
+// the harness stays idle: the packagers of the iso8583 library keep decoding state in their
+// encoders, so two connections of a server do not unpack at the same time in a -race test.
+func TestSendFault(t *testing.T) {
+	faults := []Fault{LengthTooLong, Oversized, Truncated}
+
+	for _, fault := range faults {
+		t.Run(fault.String(), func(t *testing.T) {
+			h := New(t, loadPackager(t), approve, WithTimeout(200*time.Millisecond))
+
+			conn := h.SendFault(fault, h.Message(request()))
+			AssertClosed(t, conn, time.Second)
+		})
+	}
+}
+
+// TestLeak checks that Close reports the goroutines left by the handlers of its harness,
+// and only those: not the ones of the test nor the ones of another harness
+func TestLeak(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	leaky := func(c *ctx.RequestContext, srv *server.Server) {
+		go func() {
+			<-release
+		}()
+
+		approve(c, srv)
+	}
+
+	leakyT := &recorder{TB: t}
+	leakyH := New(leakyT, loadPackager(t), leaky)
+
+	cleanT := &recorder{TB: t}
+	cleanH := New(cleanT, loadPackager(t), approve)
+
+	go func() {
+		<-release
+	}()
+
+	for _, h := range []*Harness{leakyH, cleanH} {
+		_, err := h.Do(h.Message(request()))
+		if err != nil {
+			t.Fatalf("do: %v", err)
+		}
+	}
+
+	cleanH.Close()
+	if errs := cleanT.reported(); len(errs) > 0 {
+		t.Errorf("clean harness reported %q", errs)
+	}
+
+	leakyH.Close()
+	errs := leakyT.reported()
+	if len(errs) != 1 || !strings.Contains(errs[0], "1 goroutines leaked") || !strings.Contains(errs[0], "TestLeak") {
+		t.Errorf("leaky harness reported %q, want the goroutine of the handler", errs)
+	}
+}
@@ -0,0 +1,30 @@
+package header
+
+import (
+	"fmt"
+	"io"
+)
+
+// Fixed returns the functions for a header of len(value) bytes: pack always writes value
+// and unpack reads the header and returns its bytes
+func Fixed(value []byte) (PackFunc, UnpackFunc) {
+	pack := func(interface{}) ([]byte, int, error) {
+		return value, len(value), nil
+	}
+
+	unpack := func(r io.Reader) (interface{}, int, error) {
+		buf := make([]byte, len(value))
+		_, err := io.ReadFull(r, buf)
+		if err != nil {
+			if err != io.EOF {
+				err = fmt.Errorf("reading header: %w", err)
+			}
+
+			return nil, 0, err
+		}
+
+		return buf, len(buf), nil
+	}
+
+	return pack, unpack
+}
@@ -0,0 +1,138 @@
+// Package journal keeps a durable record of every message sent and received
+// by servers and clients.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/mask"
+	"github.com/tomasdemarco/iso8583/message"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Journal records the messages that pass through a server or a client
+type Journal interface {
+	Append(entry *Entry) error
+	Close() error
+}
+
+// Direction of a message from the point of view of the component that journals it
+type Direction int
+
+const (
+	Received Direction = iota
+	Sent
+)
+
+var directionStrings = [...]string{
+	Received: "received",
+	Sent:     "sent",
+}
+
+// String return string
+func (d *Direction) String() string {
+	return directionStrings[*d]
+}
+
+// MarshalJSON override default marshal json
+func (d Direction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON override default unmarshal json
+func (d *Direction) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+
+	for i, str := range directionStrings {
+		if str == j {
+			*d = Direction(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidDirection, j)
+}
+
+// Entry is a journal record of one message
+type Entry struct {
+	Time       time.Time         `json:"time"`
+	Service    string            `json:"service,omitempty"`
+	Direction  Direction         `json:"direction"`
+	Id         string            `json:"id,omitempty"`
+	ConnId     string            `json:"connId,omitempty"`
+	TraceId    string            `json:"traceId,omitempty"`
+	Mti        string            `json:"mti,omitempty"`
+	Stan       string            `json:"stan,omitempty"`
+	Rrn        string            `json:"rrn,omitempty"`
+	TerminalId string            `json:"terminalId,omitempty"`
+	Header     string            `json:"header,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	// Raw is the hex dump of the packed message. With a masking policy it is the dump of
+	// mask.Policy.Hex: the masked message packed again with zeros, or mask.Suppressed.
+	Raw string `json:"raw,omitempty"`
+}
+
+// NewEntry builds the entry of msg, with its fields and its raw frame masked by policy when it
+// is not nil. raw is the packed message as it was read from or written to the connection, it is
+// only journaled as is without a policy.
+func NewEntry(service string, direction Direction, c ctx.Context, msg *message.Message, raw []byte, policy *mask.Policy) *Entry {
+	entry := Entry{
+		Time:      time.Now(),
+		Service:   service,
+		Direction: direction,
+		Fields:    make(map[string]string),
+	}
+
+	if raw != nil {
+		if policy != nil {
+			entry.Raw = policy.Hex(msg)
+		} else {
+			entry.Raw = fmt.Sprintf("%X", raw)
+		}
+	}
+
+	if c != nil {
+		entry.Id = c.GetId().String()
+
+		if attributes := c.Attributes(); attributes != nil {
+			entry.ConnId = (*attributes)["connId"]
+			entry.TraceId = (*attributes)["traceId"]
+		}
+	}
+
+	if msg == nil {
+		return &entry
+	}
+
+	switch header := msg.Header.(type) {
+	case []byte:
+		entry.Header = fmt.Sprintf("%X", header)
+	case nil:
+	default:
+		entry.Header = fmt.Sprintf("%v", header)
+	}
+
+	if policy != nil {
+		msg = policy.Message(msg)
+	}
+
+	for fieldId, value := range msg.Fields {
+		if fieldId != 1 {
+			entry.Fields[strconv.Itoa(fieldId)] = value
+		}
+	}
+
+	entry.Mti = msg.Fields[0]
+	entry.Stan = msg.Fields[11]
+	entry.Rrn = strings.TrimSpace(msg.Fields[37])
+	entry.TerminalId = strings.TrimSpace(msg.Fields[41])
+
+	return &entry
+}
@@ -0,0 +1,11 @@
+package journal
+
+import "errors"
+
+var (
+	ErrInvalidDirection  = errors.New("invalid journal direction")
+	ErrInvalidSyncPolicy = errors.New("invalid journal sync policy")
+	ErrJournalClosed     = errors.New("journal closed")
+	ErrOpenSegment       = errors.New("failed to open journal segment")
+	ErrReadSegment       = errors.New("failed to read journal segment")
+)
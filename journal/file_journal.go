@@ -0,0 +1,426 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dateFormat    = "20060102"
+	segmentSuffix = ".jsonl"
+)
+
+// FileJournal appends the entries as JSON lines to segment files named
+// <date>-<sequence>.jsonl in a directory. A new segment is started every day and when
+// the current one reaches the maximum size. Entries are indexed by date, terminal ID (DE41)
+// and RRN (DE37); the index is rebuilt from the segments when the journal is opened.
+type FileJournal struct {
+	mu             sync.Mutex
+	dir            string
+	maxSegmentSize int64
+	syncPolicy     SyncPolicy
+	syncInterval   time.Duration
+
+	file    *os.File
+	segment string
+	size    int64
+	dirty   bool
+	done    chan struct{}
+
+	dates     map[string][]string
+	terminals map[string][]position
+	rrns      map[string][]position
+}
+
+type position struct {
+	segment string
+	offset  int64
+}
+
+type FileOption func(*FileJournal)
+
+// WithMaxSegmentSize starts a new segment when the current one reaches size bytes
+func WithMaxSegmentSize(size int64) FileOption {
+	return func(j *FileJournal) {
+		j.maxSegmentSize = size
+	}
+}
+
+// WithSyncPolicy sets when the segment is synced to disk, with SyncInterval it is
+// synced every interval
+func WithSyncPolicy(policy SyncPolicy, interval time.Duration) FileOption {
+	return func(j *FileJournal) {
+		j.syncPolicy = policy
+		j.syncInterval = interval
+	}
+}
+
+// NewFileJournal opens the journal in dir, creating it when it does not exist
+func NewFileJournal(dir string, opts ...FileOption) (*FileJournal, error) {
+	j := FileJournal{
+		dir:            dir,
+		maxSegmentSize: 64 * 1024 * 1024,
+		syncPolicy:     SyncAlways,
+		syncInterval:   time.Second,
+		dates:          make(map[string][]string),
+		terminals:      make(map[string][]position),
+		rrns:           make(map[string][]position),
+	}
+
+	for _, opt := range opts {
+		opt(&j)
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpenSegment, err)
+	}
+
+	err = j.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	if j.syncPolicy == SyncInterval && j.syncInterval > 0 {
+		j.done = make(chan struct{})
+		go j.syncLoop()
+	}
+
+	return &j, nil
+}
+
+// Append writes the entry to the current segment
+func (j *FileJournal) Append(entry *Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	value = append(value, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.dates == nil {
+		return ErrJournalClosed
+	}
+
+	err = j.rollSegment(entry.Time, int64(len(value)))
+	if err != nil {
+		return err
+	}
+
+	offset := j.size
+	n, err := j.file.Write(value)
+	j.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	j.index(entry, position{j.segment, offset})
+
+	if j.syncPolicy == SyncAlways {
+		return j.file.Sync()
+	}
+
+	j.dirty = true
+
+	return nil
+}
+
+// ByDate returns the entries journaled on the date of t
+func (j *FileJournal) ByDate(t time.Time) ([]*Entry, error) {
+	j.mu.Lock()
+	segments := append([]string(nil), j.dates[t.Format(dateFormat)]...)
+	j.mu.Unlock()
+
+	entries := make([]*Entry, 0)
+	for _, segment := range segments {
+		err := j.scan(segment, func(entry *Entry, _ int64) {
+			entries = append(entries, entry)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// ByTerminal returns the entries with the terminal ID (DE41) terminalId
+func (j *FileJournal) ByTerminal(terminalId string) ([]*Entry, error) {
+	j.mu.Lock()
+	positions := append([]position(nil), j.terminals[strings.TrimSpace(terminalId)]...)
+	j.mu.Unlock()
+
+	return j.read(positions)
+}
+
+// ByRrn returns the entries with the retrieval reference number (DE37) rrn
+func (j *FileJournal) ByRrn(rrn string) ([]*Entry, error) {
+	j.mu.Lock()
+	positions := append([]position(nil), j.rrns[strings.TrimSpace(rrn)]...)
+	j.mu.Unlock()
+
+	return j.read(positions)
+}
+
+// Sync flushes the current segment to disk
+func (j *FileJournal) Sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.sync()
+}
+
+// Close syncs and closes the current segment
+func (j *FileJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.dates == nil {
+		return nil
+	}
+
+	if j.done != nil {
+		close(j.done)
+	}
+
+	j.dates = nil
+
+	if j.file == nil {
+		return nil
+	}
+
+	err := j.file.Sync()
+	if cErr := j.file.Close(); err == nil {
+		err = cErr
+	}
+	j.file = nil
+
+	return err
+}
+
+func (j *FileJournal) sync() error {
+	if j.file == nil || !j.dirty {
+		return nil
+	}
+
+	j.dirty = false
+
+	return j.file.Sync()
+}
+
+func (j *FileJournal) syncLoop() {
+	ticker := time.NewTicker(j.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = j.Sync()
+		case <-j.done:
+			return
+		}
+	}
+}
+
+// rollSegment opens a new segment when there is none, the date changed or
+// writing length bytes would exceed the maximum size
+func (j *FileJournal) rollSegment(t time.Time, length int64) error {
+	date := t.Format(dateFormat)
+
+	if j.file != nil && strings.HasPrefix(j.segment, date) &&
+		(j.maxSegmentSize <= 0 || j.size == 0 || j.size+length <= j.maxSegmentSize) {
+		return nil
+	}
+
+	if j.file != nil {
+		err := j.file.Sync()
+		if err != nil {
+			return err
+		}
+
+		err = j.file.Close()
+		if err != nil {
+			return err
+		}
+		j.file = nil
+	}
+
+	segment := fmt.Sprintf("%s-%06d%s", date, len(j.dates[date])+1, segmentSuffix)
+	file, err := os.OpenFile(filepath.Join(j.dir, segment), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrOpenSegment, err)
+	}
+
+	size, err := truncateTorn(file)
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", ErrOpenSegment, err)
+	}
+
+	j.file = file
+	j.segment = segment
+	j.size = size
+	j.dates[date] = append(j.dates[date], segment)
+
+	return nil
+}
+
+// truncateTorn removes the bytes after the last line of a reopened segment, left by a write torn
+// by a crash, so the next entry starts on a line of its own. It returns the size of the segment.
+func truncateTorn(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size := info.Size()
+	buf := make([]byte, 4096)
+
+	// end is the size up to the last newline, searched backwards a block at a time
+	end := size
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+
+		_, err = file.ReadAt(buf[:end-start], start)
+		if err != nil {
+			return 0, err
+		}
+
+		if i := bytes.LastIndexByte(buf[:end-start], '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+
+	if end < size {
+		err = file.Truncate(end)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return end, nil
+}
+
+func (j *FileJournal) index(entry *Entry, pos position) {
+	if entry.TerminalId != "" {
+		j.terminals[entry.TerminalId] = append(j.terminals[entry.TerminalId], pos)
+	}
+
+	if entry.Rrn != "" {
+		j.rrns[entry.Rrn] = append(j.rrns[entry.Rrn], pos)
+	}
+}
+
+// loadIndex scans the existing segments and reopens the last one of today
+func (j *FileJournal) loadIndex() error {
+	segments, err := filepath.Glob(filepath.Join(j.dir, "*"+segmentSuffix))
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(segments)
+
+	for _, path := range segments {
+		segment := filepath.Base(path)
+		date, _, _ := strings.Cut(segment, "-")
+		j.dates[date] = append(j.dates[date], segment)
+
+		err = j.scan(segment, func(entry *Entry, offset int64) {
+			j.index(entry, position{segment, offset})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	today := time.Now().Format(dateFormat)
+	if last := j.dates[today]; len(last) > 0 {
+		// the last segment of today is reopened, rollSegment names new ones after it
+		j.dates[today] = last[:len(last)-1]
+
+		return j.rollSegment(time.Now(), 0)
+	}
+
+	return nil
+}
+
+// scan calls fn with every entry of segment and its offset
+func (j *FileJournal) scan(segment string, fn func(entry *Entry, offset int64)) error {
+	file, err := os.Open(filepath.Join(j.dir, segment))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrReadSegment, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry Entry
+			// a line that can not be decoded (e.g. a torn write) is skipped
+			if json.Unmarshal(line, &entry) == nil {
+				fn(&entry, offset)
+			}
+		}
+		offset += int64(len(line))
+
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+func (j *FileJournal) read(positions []position) ([]*Entry, error) {
+	entries := make([]*Entry, 0, len(positions))
+	files := make(map[string]*os.File)
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+		}
+	}()
+
+	for _, pos := range positions {
+		file, ok := files[pos.segment]
+		if !ok {
+			var err error
+			file, err = os.Open(filepath.Join(j.dir, pos.segment))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrReadSegment, err)
+			}
+			files[pos.segment] = file
+		}
+
+		_, err := file.Seek(pos.offset, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadSegment, err)
+		}
+
+		line, err := bufio.NewReader(file).ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadSegment, err)
+		}
+
+		var entry Entry
+		err = json.Unmarshal(line, &entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadSegment, err)
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,204 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendEntries(t *testing.T, j *FileJournal, entries ...*Entry) {
+	t.Helper()
+
+	for _, entry := range entries {
+		err := j.Append(entry)
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+}
+
+func segments(t *testing.T, dir string) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = filepath.Base(path)
+	}
+
+	return names
+}
+
+func stans(entries []*Entry) []string {
+	values := make([]string, len(entries))
+	for i, entry := range entries {
+		values[i] = entry.Stan
+	}
+
+	return values
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestFileJournalMaxSegmentSize(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, time.March, 5, 10, 0, 0, 0, time.Local)
+
+	j, err := NewFileJournal(dir, WithMaxSegmentSize(200), WithSyncPolicy(SyncNever, 0))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer j.Close()
+
+	for _, stan := range []string{"000001", "000002", "000003", "000004"} {
+		appendEntries(t, j, &Entry{Time: day, Mti: "0200", Stan: stan, TerminalId: "00000001"})
+	}
+
+	got := segments(t, dir)
+	if len(got) < 2 || got[0] != "20240305-000001.jsonl" || got[1] != "20240305-000002.jsonl" {
+		t.Fatalf("segments %q, want more than one of 20240305", got)
+	}
+
+	entries, err := j.ByDate(day)
+	if err != nil {
+		t.Fatalf("by date: %v", err)
+	}
+
+	if want := []string{"000001", "000002", "000003", "000004"}; !equal(stans(entries), want) {
+		t.Errorf("entries %q, want %q", stans(entries), want)
+	}
+}
+
+func TestFileJournalDate(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, time.March, 5, 23, 59, 0, 0, time.Local)
+	next := day.Add(2 * time.Minute)
+
+	j, err := NewFileJournal(dir)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer j.Close()
+
+	appendEntries(t, j,
+		&Entry{Time: day, Stan: "000001"},
+		&Entry{Time: next, Stan: "000002"},
+	)
+
+	if got := segments(t, dir); !equal(got, []string{"20240305-000001.jsonl", "20240306-000001.jsonl"}) {
+		t.Errorf("segments %q, want one per day", got)
+	}
+
+	entries, err := j.ByDate(next)
+	if err != nil || !equal(stans(entries), []string{"000002"}) {
+		t.Errorf("entries of the next day %q, %v", stans(entries), err)
+	}
+}
+
+func TestFileJournalIndex(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	j, err := NewFileJournal(dir, WithMaxSegmentSize(150))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	appendEntries(t, j,
+		&Entry{Time: now, Stan: "000001", TerminalId: "00000001", Rrn: "000000000001"},
+		&Entry{Time: now, Stan: "000002", TerminalId: "00000002", Rrn: "000000000002"},
+		&Entry{Time: now, Stan: "000003", TerminalId: "00000001", Rrn: "000000000001"},
+	)
+
+	check := func(j *FileJournal) {
+		t.Helper()
+
+		entries, err := j.ByTerminal("00000001")
+		if err != nil || !equal(stans(entries), []string{"000001", "000003"}) {
+			t.Errorf("by terminal %q, %v", stans(entries), err)
+		}
+
+		entries, err = j.ByRrn("000000000002")
+		if err != nil || !equal(stans(entries), []string{"000002"}) {
+			t.Errorf("by rrn %q, %v", stans(entries), err)
+		}
+	}
+
+	check(j)
+
+	err = j.Close()
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	err = j.Append(&Entry{Time: now})
+	if !errors.Is(err, ErrJournalClosed) {
+		t.Errorf("append after close returned %v, want %v", err, ErrJournalClosed)
+	}
+
+	// a write torn by a crash leaves the last segment without its final newline
+	files := segments(t, dir)
+	f, err := os.OpenFile(filepath.Join(dir, files[len(files)-1]), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = f.WriteString("{\"stan\":\"0000")
+	_ = f.Close()
+
+	// the index is rebuilt when the journal is opened again, and without a maximum size the
+	// next entry is appended to the torn segment
+	j, err = NewFileJournal(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer j.Close()
+
+	check(j)
+
+	appendEntries(t, j, &Entry{Time: now, Stan: "000004", TerminalId: "00000001"})
+
+	entries, err := j.ByTerminal("00000001")
+	if err != nil || !equal(stans(entries), []string{"000001", "000003", "000004"}) {
+		t.Errorf("by terminal after reopening %q, %v", stans(entries), err)
+	}
+
+	// the entry appended after reopening does not join the torn line
+	entries, err = j.ByDate(now)
+	if err != nil || !equal(stans(entries), []string{"000001", "000002", "000003", "000004"}) {
+		t.Errorf("by date after reopening %q, %v", stans(entries), err)
+	}
+
+	err = j.Close()
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	j, err = NewFileJournal(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer j.Close()
+
+	entries, err = j.ByTerminal("00000001")
+	if err != nil || !equal(stans(entries), []string{"000001", "000003", "000004"}) {
+		t.Errorf("by terminal after reopening again %q, %v", stans(entries), err)
+	}
+}
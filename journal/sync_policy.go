@@ -0,0 +1,44 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SyncPolicy defines when the journal file is flushed to stable storage
+type SyncPolicy int
+
+const (
+	SyncAlways SyncPolicy = iota
+	SyncInterval
+	SyncNever
+)
+
+var syncPolicyStrings = [...]string{
+	SyncAlways:   "ALWAYS",
+	SyncInterval: "INTERVAL",
+	SyncNever:    "NEVER",
+}
+
+// String return string
+func (s *SyncPolicy) String() string {
+	return syncPolicyStrings[*s]
+}
+
+// UnmarshalJSON override default unmarshal json
+func (s *SyncPolicy) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+
+	for i, str := range syncPolicyStrings {
+		if str == j {
+			*s = SyncPolicy(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidSyncPolicy, j)
+}
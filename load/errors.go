@@ -0,0 +1,8 @@
+package load
+
+import "errors"
+
+var (
+	ErrNoTemplates        = errors.New("no request templates")
+	ErrInvalidConcurrency = errors.New("invalid concurrency")
+)
@@ -0,0 +1,197 @@
+// Package load generates traffic through a client to measure the throughput and latency of a host.
+package load
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/client"
+	"github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/iso8583/message"
+	"net"
+	"sync"
+	"time"
+)
+
+// Template holds the fields of the requests, by field id
+type Template map[int]string
+
+// Generator sends requests built from its templates in turn, for Duration,
+// at the target Rate or as fast as the workers allow
+type Generator struct {
+	Client    *client.Client
+	Templates []Template
+	// Rate is the target of requests per second, 0 sends a new request as soon as a worker is free
+	Rate float64
+	// Concurrency is the number of workers, that is, the maximum of requests waiting for a response
+	Concurrency int
+	Duration    time.Duration
+	// Rewrite is called for every request after the STAN and the dates are set
+	Rewrite func(msg *message.Message)
+}
+
+func NewGenerator(c *client.Client, templates []Template) *Generator {
+	return &Generator{
+		Client:      c,
+		Templates:   templates,
+		Concurrency: 10,
+		Duration:    10 * time.Second,
+	}
+}
+
+// Run sends requests until Duration elapses, waits for the responses in flight and returns the report
+func (g *Generator) Run() (*Report, error) {
+	if len(g.Templates) == 0 {
+		return nil, ErrNoTemplates
+	}
+
+	if g.Concurrency <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidConcurrency, g.Concurrency)
+	}
+
+	collector := newCollector()
+	done := make(chan struct{})
+	start := time.Now()
+
+	// in rate mode the workers take the scheduled time of each request from slots, the ones
+	// that find every worker busy wait in its buffer up to Concurrency, the rest are dropped
+	var slots chan time.Time
+	if g.Rate > 0 {
+		slots = make(chan time.Time, g.Concurrency)
+		go g.schedule(start, slots, collector, done)
+	}
+
+	wg := sync.WaitGroup{}
+	var mu sync.Mutex
+	next := 0
+
+	for i := 0; i < g.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				var scheduled time.Time
+				if slots != nil {
+					select {
+					case scheduled = <-slots:
+					case <-done:
+						return
+					}
+				} else {
+					select {
+					case <-done:
+						return
+					default:
+					}
+				}
+
+				mu.Lock()
+				template := g.Templates[next%len(g.Templates)]
+				next++
+				mu.Unlock()
+
+				collector.add(g.send(template, scheduled))
+			}
+		}()
+	}
+
+	time.Sleep(g.Duration)
+	close(done)
+	wg.Wait()
+
+	// the slots left in the buffer were never sent
+	if slots != nil {
+		collector.drop(len(slots))
+	}
+
+	return collector.report(start, time.Since(start), g.Rate, g.Concurrency), nil
+}
+
+// schedule puts the time of a slot in slots every 1/Rate seconds, measured from start so delays do
+// not accumulate. It never waits for a worker: a slot that does not fit in slots is dropped.
+func (g *Generator) schedule(start time.Time, slots chan<- time.Time, collector *collector, done <-chan struct{}) {
+	for i := 0; ; i++ {
+		at := start.Add(g.interval() * time.Duration(i))
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return
+		}
+
+		select {
+		case slots <- at:
+		default:
+			collector.drop(1)
+		}
+	}
+}
+
+// interval returns the time between two slots at the target Rate
+func (g *Generator) interval() time.Duration {
+	return time.Duration(float64(time.Second) / g.Rate)
+}
+
+// send sends a request built from template with a unique STAN and returns its outcome. The latency of
+// a scheduled request is measured from its slot, so it includes the time it waited for a worker.
+func (g *Generator) send(template Template, scheduled time.Time) sample {
+	msg := message.NewMessage(g.Client.Packager)
+	for fieldId, value := range template {
+		msg.SetField(fieldId, value)
+	}
+
+	now := time.Now()
+	msg.SetField(7, now.UTC().Format("0102150405"))
+	msg.SetField(11, fmt.Sprintf("%06d", g.Client.Stan.Next()))
+
+	if _, err := msg.GetField(12); err == nil {
+		msg.SetField(12, now.Format("150405"))
+	}
+
+	if _, err := msg.GetField(13); err == nil {
+		msg.SetField(13, now.Format("0102"))
+	}
+
+	if g.Rewrite != nil {
+		g.Rewrite(msg)
+	}
+
+	start := time.Now()
+	late := false
+	if !scheduled.IsZero() {
+		// a request is late when it is sent after the slot of the next one
+		late = start.Sub(scheduled) > g.interval()
+		start = scheduled
+	}
+
+	response, err := g.Client.Do(context.NewRequestContext(nil, msg), msg)
+	elapsed := time.Since(start)
+	if err != nil {
+		return sample{elapsed: elapsed, errorType: errorType(err), late: late}
+	}
+
+	responseCode, err := response.GetField(39)
+	if err != nil {
+		responseCode = NoResponseCode
+	}
+
+	return sample{elapsed: elapsed, responseCode: responseCode, late: late}
+}
+
+// errorType classifies the errors of the requests that got no response
+func errorType(err error) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, client.ErrTimeout):
+		return "timeout"
+	case errors.Is(err, client.ErrNotSent):
+		return "not sent"
+	case errors.Is(err, net.ErrClosed), errors.As(err, &netErr):
+		return "network"
+	default:
+		return "other"
+	}
+}
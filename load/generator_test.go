@@ -0,0 +1,53 @@
+package load
+
+import (
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"testing"
+	"time"
+)
+
+func TestRunRate(t *testing.T) {
+	pkg, err := subfield.LoadFromJson("../iso8583/packager", "iso87BPackager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	// the host takes longer than the slots, so the single worker cannot keep the rate
+	h := gopostest.New(t, pkg, func(c *ctx.RequestContext, srv *server.Server) {
+		time.Sleep(20 * time.Millisecond)
+
+		response, err := srv.NewResponse(c.Request)
+		if err != nil {
+			return
+		}
+
+		response.SetField(39, "00")
+		_ = srv.SendResponse(c, response)
+	})
+
+	g := NewGenerator(h.Client, []Template{{0: "0200", 3: "000000", 4: "000000001000", 41: "00000001"}})
+	g.Rate = 200
+	g.Concurrency = 1
+	g.Duration = 300 * time.Millisecond
+
+	report, err := g.Run()
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if report.Responses == 0 || report.ResponseCodes["00"] != report.Responses {
+		t.Fatalf("report %+v", report)
+	}
+
+	if report.AchievedRate >= report.TargetRate || report.DroppedSlots == 0 || report.LateSlots == 0 {
+		t.Errorf("achieved %g of %g tps, %d late and %d dropped slots", report.AchievedRate, report.TargetRate, report.LateSlots, report.DroppedSlots)
+	}
+
+	// the requests that waited for the worker count the wait in their latency
+	if report.Latency.Max < 30 {
+		t.Errorf("latency %+v, want the wait for the worker", report.Latency)
+	}
+}
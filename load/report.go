@@ -0,0 +1,181 @@
+package load
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NoResponseCode counts the responses without DE39
+const NoResponseCode = "none"
+
+// HistogramBounds are the upper bounds in milliseconds of the latency histogram buckets
+var HistogramBounds = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// Report is the result of a run. Latencies are in milliseconds and only count the requests
+// that got a response, whatever its response code. With a target rate they are measured from
+// the slot of each request, LateSlots counts the requests sent after the slot of the next one
+// and DroppedSlots the slots not sent, as every worker was busy and the queue full.
+type Report struct {
+	Start         time.Time      `json:"start"`
+	Duration      float64        `json:"duration"`
+	TargetRate    float64        `json:"targetRate,omitempty"`
+	AchievedRate  float64        `json:"achievedRate"`
+	LateSlots     int            `json:"lateSlots,omitempty"`
+	DroppedSlots  int            `json:"droppedSlots,omitempty"`
+	Concurrency   int            `json:"concurrency"`
+	Requests      int            `json:"requests"`
+	Responses     int            `json:"responses"`
+	Errors        int            `json:"errors"`
+	Throughput    float64        `json:"throughput"`
+	ResponseCodes map[string]int `json:"responseCodes"`
+	ErrorTypes    map[string]int `json:"errorTypes"`
+	Latency       Latency        `json:"latency"`
+}
+
+// Latency summarizes the response times in milliseconds
+type Latency struct {
+	Min       float64  `json:"min"`
+	Mean      float64  `json:"mean"`
+	Max       float64  `json:"max"`
+	P50       float64  `json:"p50"`
+	P95       float64  `json:"p95"`
+	P99       float64  `json:"p99"`
+	P999      float64  `json:"p999"`
+	Histogram []Bucket `json:"histogram"`
+}
+
+// Bucket counts the responses received in up to Le milliseconds and more than the previous bucket.
+// The last bucket has no bound (Le 0) and counts the slower ones.
+type Bucket struct {
+	Le    float64 `json:"le,omitempty"`
+	Count int     `json:"count"`
+}
+
+type sample struct {
+	elapsed      time.Duration
+	responseCode string
+	errorType    string
+	late         bool
+}
+
+type collector struct {
+	mu            sync.Mutex
+	requests      int
+	errors        int
+	late          int
+	dropped       int
+	responseCodes map[string]int
+	errorTypes    map[string]int
+	latencies     []time.Duration
+}
+
+func newCollector() *collector {
+	return &collector{
+		responseCodes: make(map[string]int),
+		errorTypes:    make(map[string]int),
+	}
+}
+
+func (c *collector) add(s sample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests++
+	if s.late {
+		c.late++
+	}
+
+	if s.errorType != "" {
+		c.errors++
+		c.errorTypes[s.errorType]++
+		return
+	}
+
+	c.responseCodes[s.responseCode]++
+	c.latencies = append(c.latencies, s.elapsed)
+}
+
+func (c *collector) drop(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropped += n
+}
+
+func (c *collector) report(start time.Time, elapsed time.Duration, rate float64, concurrency int) *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := Report{
+		Start:         start,
+		Duration:      elapsed.Seconds(),
+		TargetRate:    rate,
+		LateSlots:     c.late,
+		DroppedSlots:  c.dropped,
+		Concurrency:   concurrency,
+		Requests:      c.requests,
+		Responses:     len(c.latencies),
+		Errors:        c.errors,
+		ResponseCodes: c.responseCodes,
+		ErrorTypes:    c.errorTypes,
+		Latency:       latency(c.latencies),
+	}
+
+	if elapsed > 0 {
+		report.AchievedRate = float64(report.Requests) / elapsed.Seconds()
+		report.Throughput = float64(report.Responses) / elapsed.Seconds()
+	}
+
+	return &report
+}
+
+func latency(latencies []time.Duration) Latency {
+	result := Latency{Histogram: make([]Bucket, len(HistogramBounds)+1)}
+	for i, bound := range HistogramBounds {
+		result.Histogram[i].Le = bound
+	}
+
+	if len(latencies) == 0 {
+		return result
+	}
+
+	sorted := make([]float64, len(latencies))
+	var total float64
+	for i, v := range latencies {
+		sorted[i] = milliseconds(v)
+		total += sorted[i]
+	}
+	sort.Float64s(sorted)
+
+	result.Min = sorted[0]
+	result.Max = sorted[len(sorted)-1]
+	result.Mean = total / float64(len(sorted))
+	result.P50 = percentile(sorted, 0.50)
+	result.P95 = percentile(sorted, 0.95)
+	result.P99 = percentile(sorted, 0.99)
+	result.P999 = percentile(sorted, 0.999)
+
+	for _, v := range sorted {
+		i := sort.SearchFloat64s(HistogramBounds, v)
+		result.Histogram[i].Count++
+	}
+
+	return result
+}
+
+// percentile returns the nearest-rank percentile p of sorted
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+// milliseconds returns d in milliseconds rounded to the microsecond
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
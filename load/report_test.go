@@ -0,0 +1,139 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/client"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	for _, tt := range []struct {
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{sorted, 0, 1},
+		{sorted, 0.10, 1},
+		{sorted, 0.11, 2},
+		{sorted, 0.50, 5},
+		{sorted, 0.95, 10},
+		{sorted, 0.999, 10},
+		{sorted, 1, 10},
+		{[]float64{7}, 0.99, 7},
+	} {
+		if got := percentile(tt.sorted, tt.p); got != tt.want {
+			t.Errorf("percentile %g of %v is %g, want %g", tt.p, tt.sorted, got, tt.want)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		latencies []time.Duration
+		// counts by bucket, the last one without bound
+		want map[float64]int
+	}{
+		{"empty", nil, map[float64]int{}},
+		// a latency on a bound is counted in its bucket
+		{"bounds", []time.Duration{time.Millisecond, 2 * time.Millisecond, 10 * time.Second}, map[float64]int{1: 1, 2: 1, 10000: 1}},
+		{"between bounds", []time.Duration{1500 * time.Microsecond, 3 * time.Millisecond, 750 * time.Millisecond}, map[float64]int{2: 1, 5: 1, 1000: 1}},
+		{"below the first bound", []time.Duration{0, 10 * time.Microsecond}, map[float64]int{1: 2}},
+		{"slower than the last bound", []time.Duration{10*time.Second + time.Millisecond, time.Minute}, map[float64]int{0: 2}},
+	} {
+		result := latency(tt.latencies)
+
+		if len(result.Histogram) != len(HistogramBounds)+1 || result.Histogram[len(HistogramBounds)].Le != 0 {
+			t.Fatalf("%s: histogram %v", tt.name, result.Histogram)
+		}
+
+		for _, bucket := range result.Histogram {
+			if bucket.Count != tt.want[bucket.Le] {
+				t.Errorf("%s: bucket %g has %d, want %d", tt.name, bucket.Le, bucket.Count, tt.want[bucket.Le])
+			}
+		}
+	}
+
+	result := latency([]time.Duration{3 * time.Millisecond, time.Millisecond, 2*time.Millisecond + 500*time.Nanosecond})
+	if result.Min != 1 || result.Max != 3 || result.Mean != 2.0003333333333333 || result.P50 != 2.001 || result.P99 != 3 {
+		t.Errorf("latency %+v", result)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorType(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{client.ErrTimeout, "timeout"},
+		{fmt.Errorf("%w: %w", client.ErrTimeout, os.ErrDeadlineExceeded), "timeout"},
+		{fmt.Errorf("%w: %w", client.ErrNotSent, net.ErrClosed), "not sent"},
+		{net.ErrClosed, "network"},
+		{&net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, "network"},
+		{fmt.Errorf("read: %w", timeoutError{}), "network"},
+		{fmt.Errorf("unpack"), "other"},
+	} {
+		if got := errorType(tt.err); got != tt.want {
+			t.Errorf("error type of %v is %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	c := newCollector()
+	c.add(sample{elapsed: 2 * time.Millisecond, responseCode: "00"})
+	c.add(sample{elapsed: 4 * time.Millisecond, responseCode: "00", late: true})
+	c.add(sample{elapsed: 5 * time.Millisecond, responseCode: "05"})
+	c.add(sample{elapsed: 2 * time.Second, errorType: "timeout", late: true})
+	c.drop(3)
+
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	report := c.report(start, 2*time.Second, 5, 2)
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	want := `{"start":"2024-01-01T12:00:00Z","duration":2,"targetRate":5,"achievedRate":2,"lateSlots":2,"droppedSlots":3,` +
+		`"concurrency":2,"requests":4,"responses":3,"errors":1,"throughput":1.5,` +
+		`"responseCodes":{"00":2,"05":1},"errorTypes":{"timeout":1},` +
+		`"latency":{"min":2,"mean":3.6666666666666665,"max":5,"p50":4,"p95":5,"p99":5,"p999":5,"histogram":[` +
+		`{"le":1,"count":0},{"le":2,"count":1},{"le":5,"count":2},{"le":10,"count":0},{"le":20,"count":0},{"le":50,"count":0},` +
+		`{"le":100,"count":0},{"le":200,"count":0},{"le":500,"count":0},{"le":1000,"count":0},{"le":2000,"count":0},` +
+		`{"le":5000,"count":0},{"le":10000,"count":0},{"count":0}]}}`
+
+	if string(b) != want {
+		t.Errorf("report\n%s\nwant\n%s", b, want)
+	}
+
+	// without a target rate the slots are left out
+	b, err = json.Marshal(newCollector().report(start, 0, 0, 1))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var fields map[string]any
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	for _, key := range []string{"targetRate", "lateSlots", "droppedSlots"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("the report without a target rate has %s: %s", key, b)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/iso8583/message"
+	"strings"
+	"time"
+)
+
+// LogEntry is the access log summary record of a request/response pair
+type LogEntry struct {
+	Time           string    `json:"time,omitempty"`
+	Id             uuid.UUID `json:"id,omitempty"`
+	ConnId         string    `json:"connId,omitempty"`
+	RemoteAddr     string    `json:"remoteAddr,omitempty"`
+	Mti            string    `json:"mti,omitempty"`
+	ProcessingCode string    `json:"processingCode,omitempty"`
+	Amount         string    `json:"amount,omitempty"`
+	Stan           string    `json:"stan,omitempty"`
+	Rrn            string    `json:"rrn,omitempty"`
+	ResponseCode   string    `json:"responseCode,omitempty"`
+	TerminalId     string    `json:"terminalId,omitempty"`
+	Outcome        Outcome   `json:"outcome"`
+	ElapsedTime    string    `json:"elapsedTime,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// NewLogEntry builds the access log entry of the request in c and its response.
+// The elapsed time is measured from the start of the request context to now.
+func NewLogEntry(c *ctx.RequestContext, response *message.Message, outcome Outcome) LogEntry {
+	entry := LogEntry{
+		Time:        c.StarTime.Format(timeFormat),
+		Id:          c.Id,
+		Outcome:     outcome,
+		ElapsedTime: fmt.Sprintf("%.3fms", float64(time.Since(c.StarTime).Nanoseconds())/1e6),
+	}
+
+	if c.ClientCtx != nil {
+		entry.ConnId = c.ClientCtx.Id.String()
+		entry.RemoteAddr = c.ClientCtx.RemoteAddr
+	}
+
+	entry.Mti = field(c.Request, response, 0)
+	entry.ProcessingCode = field(c.Request, response, 3)
+	entry.Amount = field(c.Request, response, 4)
+	entry.Stan = field(c.Request, response, 11)
+	entry.Rrn = field(c.Request, response, 37)
+	entry.TerminalId = strings.TrimSpace(field(c.Request, response, 41))
+	entry.ResponseCode = field(response, nil, 39)
+
+	return entry
+}
+
+// Access logs the access log entry at Info level with the Access log type
+func (l *Logger) Access(c ctx.Context, entry LogEntry) {
+	if l.Enabled(c, Info) {
+		value, err := json.Marshal(entry)
+		if err != nil {
+			l.Error(c, err)
+			return
+		}
+
+		l.Info(c, Access, string(value))
+	}
+}
+
+// field returns the value of fieldId in msg, or in fallback when msg does not have it
+func field(msg, fallback *message.Message, fieldId int) string {
+	for _, m := range []*message.Message{msg, fallback} {
+		if m == nil {
+			continue
+		}
+
+		if value, err := m.GetField(fieldId); err == nil {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncWriter hands every write to a background goroutine through a bounded buffer.
+// Write never blocks: when the buffer is full the record is dropped and counted.
+type AsyncWriter struct {
+	writer  io.Writer
+	buffer  chan []byte
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	written atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// NewAsyncWriter starts writing to w with a buffer of size records
+func NewAsyncWriter(w io.Writer, size int) *AsyncWriter {
+	if size <= 0 {
+		size = 1024
+	}
+
+	aw := AsyncWriter{
+		writer: w,
+		buffer: make(chan []byte, size),
+		done:   make(chan struct{}),
+	}
+
+	go aw.run()
+
+	return &aw
+}
+
+// Write queues a copy of p, it is dropped when the buffer is full
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	b := make([]byte, len(p))
+	copy(b, p)
+
+	select {
+	case w.buffer <- b:
+	default:
+		w.dropped.Add(1)
+	}
+
+	return len(p), nil
+}
+
+// Close flushes the queued records and stops the writer, the underlying writer is not closed
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.buffer)
+	w.mu.Unlock()
+
+	<-w.done
+
+	return nil
+}
+
+// Written returns the number of records written to the underlying writer
+func (w *AsyncWriter) Written() uint64 {
+	return w.written.Load()
+}
+
+// Dropped returns the number of records dropped because the buffer was full
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Failed returns the number of records the underlying writer returned an error for
+func (w *AsyncWriter) Failed() uint64 {
+	return w.failed.Load()
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	for b := range w.buffer {
+		_, err := w.writer.Write(b)
+		if err != nil {
+			w.failed.Add(1)
+			continue
+		}
+
+		w.written.Add(1)
+	}
+}
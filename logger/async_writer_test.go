@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+)
+
+// blockingWriter signals every write on entered and waits for release to return
+type blockingWriter struct {
+	entered chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.entered <- struct{}{}
+	<-w.release
+
+	return len(p), w.err
+}
+
+func TestAsyncWriterDropped(t *testing.T) {
+	bw := &blockingWriter{entered: make(chan struct{}, 3), release: make(chan struct{})}
+	w := NewAsyncWriter(bw, 1)
+
+	write := func() {
+		t.Helper()
+
+		n, err := w.Write([]byte("record\n"))
+		if n != 7 || err != nil {
+			t.Fatalf("write returned %d, %v", n, err)
+		}
+	}
+
+	// the first record is taken by the writer, the second fills the buffer
+	write()
+	<-bw.entered
+	write()
+	write()
+	write()
+
+	if got := w.Dropped(); got != 2 {
+		t.Errorf("dropped %d, want 2", got)
+	}
+
+	close(bw.release)
+
+	err := w.Close()
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if got := w.Written(); got != 2 {
+		t.Errorf("written %d, want 2", got)
+	}
+
+	_, err = w.Write([]byte("late\n"))
+	if !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("write after close returned %v, want %v", err, ErrWriterClosed)
+	}
+}
+
+func TestAsyncWriterFailed(t *testing.T) {
+	bw := &blockingWriter{entered: make(chan struct{}, 3), release: make(chan struct{}), err: errors.New("disk full")}
+	close(bw.release)
+
+	w := NewAsyncWriter(bw, 8)
+	for i := 0; i < 3; i++ {
+		_, _ = w.Write([]byte("record\n"))
+	}
+
+	err := w.Close()
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if w.Failed() != 3 || w.Written() != 0 || w.Dropped() != 0 {
+		t.Errorf("failed %d, written %d, dropped %d, want 3, 0, 0", w.Failed(), w.Written(), w.Dropped())
+	}
+}
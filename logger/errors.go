@@ -0,0 +1,9 @@
+package logger
+
+import "errors"
+
+var (
+	ErrWriterClosed  = errors.New("log writer closed")
+	ErrOpenLogFile   = errors.New("failed to open log file")
+	ErrRotateLogFile = errors.New("failed to rotate log file")
+)
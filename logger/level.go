@@ -0,0 +1,107 @@
+package logger
+
+import (
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"sync"
+)
+
+// Attribute keys commonly used for level overrides
+const (
+	ConnIdAttribute     = "connId"
+	TerminalIdAttribute = "terminalId"
+)
+
+// LevelOverride sets the level of the records whose context has the attribute Key with Value
+type LevelOverride struct {
+	Key   string   `json:"key"`
+	Value string   `json:"value"`
+	Level LogLevel `json:"level"`
+}
+
+type levelOverrides struct {
+	mu   sync.RWMutex
+	list map[[2]string]LogLevel
+}
+
+func newLevelOverrides() *levelOverrides {
+	return &levelOverrides{
+		list: make(map[[2]string]LogLevel),
+	}
+}
+
+// GetLevel returns the global level
+func (l *Logger) GetLevel() LogLevel {
+	return LogLevel(l.level.Load())
+}
+
+// SetLevel changes the global level, it is safe to call while logging
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level.Store(int32(level))
+}
+
+// SetLevelFor overrides the level for the contexts whose attribute key has value,
+// e.g. SetLevelFor(TerminalIdAttribute, "00000001", Debug) enables debug dumps for one terminal
+func (l *Logger) SetLevelFor(key, value string, level LogLevel) {
+	l.overrides.mu.Lock()
+	defer l.overrides.mu.Unlock()
+
+	l.overrides.list[[2]string{key, value}] = level
+}
+
+// ClearLevelFor removes the override set by SetLevelFor
+func (l *Logger) ClearLevelFor(key, value string) {
+	l.overrides.mu.Lock()
+	defer l.overrides.mu.Unlock()
+
+	delete(l.overrides.list, [2]string{key, value})
+}
+
+// LevelOverrides returns the current overrides
+func (l *Logger) LevelOverrides() []LevelOverride {
+	l.overrides.mu.RLock()
+	defer l.overrides.mu.RUnlock()
+
+	overrides := make([]LevelOverride, 0, len(l.overrides.list))
+	for k, v := range l.overrides.list {
+		overrides = append(overrides, LevelOverride{Key: k[0], Value: k[1], Level: v})
+	}
+
+	return overrides
+}
+
+// Enabled reports whether a record of level is logged for the context c,
+// taking into account the overrides that match its attributes
+func (l *Logger) Enabled(c ctx.Context, level LogLevel) bool {
+	return level >= l.levelFor(c)
+}
+
+func (l *Logger) levelFor(c ctx.Context) LogLevel {
+	level := l.GetLevel()
+
+	if c == nil {
+		return level
+	}
+
+	l.overrides.mu.RLock()
+	defer l.overrides.mu.RUnlock()
+
+	if len(l.overrides.list) == 0 {
+		return level
+	}
+
+	attributes := c.Attributes()
+	if attributes == nil {
+		return level
+	}
+
+	// when several overrides match, the most verbose wins
+	matched := false
+	for k, v := range *attributes {
+		if override, ok := l.overrides.list[[2]string{k, v}]; ok && (!matched || override < level) {
+			level = override
+			matched = true
+		}
+	}
+
+	return level
+}
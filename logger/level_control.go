@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+// WatchSignals changes the global level when one of the given signals is received,
+// e.g. map[os.Signal]LogLevel{syscall.SIGUSR1: Debug, syscall.SIGUSR2: Info}.
+// It returns a function that stops watching.
+func (l *Logger) WatchSignals(levels map[os.Signal]LogLevel) (stop func()) {
+	sigs := make([]os.Signal, 0, len(levels))
+	for sig := range levels {
+		sigs = append(sigs, sig)
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				level := levels[sig]
+				l.SetLevel(level)
+				l.Info(nil, Message, "log level changed to "+level.String()+" by signal "+sig.String())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+type levelState struct {
+	Level     LogLevel        `json:"level"`
+	Overrides []LevelOverride `json:"overrides"`
+}
+
+// levelRequest is the body of a PUT, the level is a pointer to tell a missing level from Debug
+type levelRequest struct {
+	Key   string    `json:"key"`
+	Value string    `json:"value"`
+	Level *LogLevel `json:"level"`
+}
+
+// LevelHandler returns an http.Handler to be mounted on an admin endpoint.
+//
+//	GET    returns the global level and the overrides
+//	PUT    {"level":"Debug"} changes the global level,
+//	       {"key":"terminalId","value":"00000001","level":"Debug"} sets an override
+//	DELETE {"key":"terminalId","value":"00000001"} removes an override
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req levelRequest
+			err := json.NewDecoder(r.Body).Decode(&req)
+			if err != nil || req.Level == nil || !req.Level.IsValid() {
+				http.Error(w, "invalid level", http.StatusBadRequest)
+				return
+			}
+
+			if req.Key == "" {
+				l.SetLevel(*req.Level)
+			} else {
+				l.SetLevelFor(req.Key, req.Value, *req.Level)
+			}
+		case http.MethodDelete:
+			var override LevelOverride
+			err := json.NewDecoder(r.Body).Decode(&override)
+			if err != nil || override.Key == "" {
+				http.Error(w, "invalid override", http.StatusBadRequest)
+				return
+			}
+
+			l.ClearLevelFor(override.Key, override.Value)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelState{
+			Level:     l.GetLevel(),
+			Overrides: l.LevelOverrides(),
+		})
+	})
+}
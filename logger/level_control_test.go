@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func request(t *testing.T, h http.Handler, method, body string) (int, levelState) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, "/log/level", strings.NewReader(body)))
+
+	var state levelState
+	if rec.Code == http.StatusOK {
+		err := json.NewDecoder(rec.Body).Decode(&state)
+		if err != nil {
+			t.Fatalf("%s %s: decode: %v", method, body, err)
+		}
+	}
+
+	return rec.Code, state
+}
+
+func TestLevelHandler(t *testing.T) {
+	l := New(Info, "test", WithWriter(io.Discard))
+	h := l.LevelHandler()
+
+	code, state := request(t, h, http.MethodGet, "")
+	if code != http.StatusOK || state.Level != Info {
+		t.Fatalf("GET returned %d, level %s", code, state.Level.String())
+	}
+
+	code, state = request(t, h, http.MethodPut, `{"level":"Warn"}`)
+	if code != http.StatusOK || state.Level != Warn || l.GetLevel() != Warn {
+		t.Errorf("PUT level returned %d, level %s", code, state.Level.String())
+	}
+
+	code, state = request(t, h, http.MethodPut, `{"key":"terminalId","value":"00000001","level":"Debug"}`)
+	if code != http.StatusOK || len(state.Overrides) != 1 || state.Overrides[0].Level != Debug {
+		t.Errorf("PUT override returned %d, overrides %v", code, state.Overrides)
+	}
+
+	code, state = request(t, h, http.MethodDelete, `{"key":"terminalId","value":"00000001"}`)
+	if code != http.StatusOK || len(state.Overrides) != 0 {
+		t.Errorf("DELETE override returned %d, overrides %v", code, state.Overrides)
+	}
+
+	code, _ = request(t, h, http.MethodPatch, "")
+	if code != http.StatusMethodNotAllowed {
+		t.Errorf("PATCH returned %d", code)
+	}
+}
+
+// TestLevelHandlerInvalid checks that the requests without a valid level change nothing,
+// a missing level is not Debug
+func TestLevelHandlerInvalid(t *testing.T) {
+	l := New(Info, "test", WithWriter(io.Discard))
+	h := l.LevelHandler()
+
+	for _, body := range []string{``, `{}`, `{"key":"terminalId","value":"00000001"}`, `{"level":"Verbose"}`, `{"level":null}`} {
+		code, _ := request(t, h, http.MethodPut, body)
+		if code != http.StatusBadRequest {
+			t.Errorf("PUT %s returned %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+
+	if level := l.GetLevel(); level != Info || len(l.LevelOverrides()) != 0 {
+		t.Errorf("the invalid requests changed the level to %s, overrides %v", level.String(), l.LevelOverrides())
+	}
+
+	code, _ := request(t, h, http.MethodDelete, `{"value":"00000001"}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("DELETE without key returned %d, want %d", code, http.StatusBadRequest)
+	}
+}
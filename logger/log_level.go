@@ -3,6 +3,7 @@ package logger
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 )
 
 type LogLevel int
@@ -33,6 +34,11 @@ func (l *LogLevel) EnumIndex() int {
 	return int(*l)
 }
 
+// MarshalJSON override default marshal json
+func (l LogLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
 // UnmarshalJSON override default unmarshal json
 func (l *LogLevel) UnmarshalJSON(b []byte) error {
 	var j string
@@ -60,3 +66,35 @@ func (l *LogLevel) IsValid() bool {
 	}
 	return false
 }
+
+// slogLevel return the equivalent slog.Level
+func (l *LogLevel) slogLevel() slog.Level {
+	switch *l {
+	case Debug:
+		return slog.LevelDebug
+	case Info:
+		return slog.LevelInfo
+	case Warn:
+		return slog.LevelWarn
+	case Error:
+		return slog.LevelError
+	default:
+		return slog.LevelError + 4
+	}
+}
+
+// fromSlogLevel return the LogLevel for a slog.Level
+func fromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return Debug
+	case level < slog.LevelWarn:
+		return Info
+	case level < slog.LevelError:
+		return Warn
+	case level < slog.LevelError+4:
+		return Error
+	default:
+		return Fatal
+	}
+}
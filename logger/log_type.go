@@ -14,6 +14,7 @@ const (
 	IsoMessage
 	Request
 	Response
+	Access
 )
 
 var logTypeStrings = [...]string{
@@ -23,6 +24,7 @@ var logTypeStrings = [...]string{
 	IsoMessage: "isoMsg",
 	Request:    "request",
 	Response:   "response",
+	Access:     "access",
 }
 
 // String return string
@@ -62,3 +64,6 @@ func (l *LogType) IsValid() bool {
 	}
 	return false
 }
+
+// TraceLogTypes are the log types that carry message traffic
+var TraceLogTypes = []LogType{IsoMessage, IsoPack, IsoUnpack}
@@ -1,62 +1,237 @@
 package logger
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/google/uuid"
 	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/emv"
+	"github.com/tomasdemarco/go-pos/mask"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/message"
+	"io"
 	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync/atomic"
 	"time"
 )
 
+const timeFormat = "2006-01-02 15:04:05.000"
+
 type Logger struct {
-	Level   LogLevel
 	Service *string
+	Masking *mask.Policy
+
+	level     atomic.Int32
+	overrides *levelOverrides
+	handler   slog.Handler
+	routes    map[LogType]slog.Handler
+}
+
+type Option func(*Logger)
+
+// WithHandler sets the slog.Handler that receives every record
+func WithHandler(handler slog.Handler) Option {
+	return func(l *Logger) {
+		l.handler = handler
+	}
+}
+
+// WithMasking sets the masking policy applied to messages before they are logged,
+// nil disables masking
+func WithMasking(policy *mask.Policy) Option {
+	return func(l *Logger) {
+		l.Masking = policy
+	}
+}
+
+// WithRoute sends the records of the given log types to handler instead of the default handler,
+// e.g. to write isoMsg, pack and unpack traffic to a separate trace file
+func WithRoute(handler slog.Handler, logTypes ...LogType) Option {
+	return func(l *Logger) {
+		if l.routes == nil {
+			l.routes = make(map[LogType]slog.Handler)
+		}
+
+		for _, logType := range logTypes {
+			l.routes[logType] = handler
+		}
+	}
+}
+
+// WithWriter writes the records as JSON lines to w
+func WithWriter(w io.Writer) Option {
+	return func(l *Logger) {
+		l.handler = NewJSONHandler(w)
+	}
+}
+
+func New(level LogLevel, service string, opts ...Option) *Logger {
+	l := Logger{
+		Service:   &service,
+		Masking:   mask.DefaultPolicy(),
+		overrides: newLevelOverrides(),
+		handler:   NewJSONHandler(os.Stderr),
+	}
+
+	l.level.Store(int32(level))
+
+	for _, opt := range opts {
+		opt(&l)
+	}
+
+	return &l
+}
+
+// Handler returns the slog.Handler used by the logger for logType
+func (l *Logger) Handler(logType LogType) slog.Handler {
+	if handler, ok := l.routes[logType]; ok {
+		return handler
+	}
+
+	return l.handler
+}
+
+// NewJSONHandler returns a slog.JSONHandler that keeps the go-pos record layout:
+// time formatted as "2006-01-02 15:04:05.000", level names of LogLevel and no msg key.
+// Level filtering is done by Logger, so the handler accepts every level.
+func NewJSONHandler(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       slog.Level(-8),
+		ReplaceAttr: replaceAttr,
+	})
 }
 
-func New(level LogLevel, service string) *Logger {
-	// Logs without flags
-	log.SetFlags(0)
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
 
-	return &Logger{
-		level,
-		&service,
+	switch a.Key {
+	case slog.TimeKey:
+		return slog.String(slog.TimeKey, a.Value.Time().Format(timeFormat))
+	case slog.LevelKey:
+		if lvl, ok := a.Value.Any().(slog.Level); ok {
+			logLevel := fromSlogLevel(lvl)
+			return slog.String(slog.LevelKey, logLevel.String())
+		}
+	case slog.MessageKey:
+		if a.Value.String() == "" {
+			return slog.Attr{}
+		}
 	}
+
+	return a
 }
 
-// LogEntry define la estructura del log en formato JSON
-type LogEntry struct {
-	Time        string    `json:"time,omitempty"`
-	Id          uuid.UUID `json:"id,omitempty"`
-	RemoteAddr  string    `json:"remoteAddr,omitempty"`
-	StatusCode  int       `json:"statusCode,omitempty"`
-	ElapsedTime string    `json:"elapsedTime,omitempty"`
+// MessageLog returns the JSON representation of msg with the masking policy applied,
+// the EMV data (DE55) as a list of tags and the fields with registered subfields decoded
+func (l *Logger) MessageLog(msg *message.Message) string {
+	if l.Masking != nil {
+		msg = l.Masking.Message(msg)
+	}
+
+	return decodedLog(msg, l.Masking)
 }
 
-// CustomLogger es un middleware para loguear en formato JSON
-func CustomLogger(c *ctx.RequestContext) {
-	//gin.HandlerFunc {
-	//	return func(c *ctx.Context){
+// decodedLog returns msg.Log() with the value of DE55 replaced by its decoded tags and the
+// fields with subfields replaced by their values by subfield id, masked with policy when it is set.
+// Fields that can not be decoded are left unchanged.
+func decodedLog(msg *message.Message, policy *mask.Policy) string {
+	value := msg.Log()
 
-	start := time.Now()
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(value), &fields)
+	if err != nil {
+		return value
+	}
 
-	// Crea la entrada del log
-	entry := LogEntry{
-		Time: start.Format("2006-01-02 15:04:05.000"),
-		Id:   c.Id,
-		//RemoteAddr:  c.RemoteAddr.String(),
-		ElapsedTime: time.Since(start).String(),
+	if tlvs, err := emv.FromMessage(msg); err == nil {
+		if b, err := json.Marshal(tlvs.Log()); err == nil {
+			fields[strconv.Itoa(emv.Field)] = b
+		}
 	}
 
-	logJSON(entry)
+	for fieldId, subfields := range subfield.Definitions(msg.Packager) {
+		fieldValue, err := msg.GetField(fieldId)
+		if err != nil {
+			continue
+		}
+
+		values, err := subfields.Unpack(fieldValue)
+		if err != nil {
+			continue
+		}
+
+		logValues := make(map[string]string, len(values))
+		for subfieldId, subfieldValue := range values {
+			if policy != nil {
+				var ok bool
+				subfieldValue, ok = policy.SubfieldValue(fieldId, subfieldId, subfieldValue)
+				if !ok {
+					continue
+				}
+			}
+			logValues[strconv.Itoa(subfieldId)] = subfieldValue
+		}
+
+		if b, err := json.Marshal(logValues); err == nil {
+			fields[strconv.Itoa(fieldId)] = b
+		}
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return value
+	}
+
+	return string(b)
 }
 
-//}
+// MessageHex returns the hex dump of raw, or the dump of msg re-packed with the
+// masking policy applied when a policy is set
+func (l *Logger) MessageHex(msg *message.Message, raw []byte) string {
+	if l.Masking == nil {
+		return fmt.Sprintf("%X", raw)
+	}
 
-// logJSON convierte la entrada del log a JSON y la imprime
-func logJSON(entry LogEntry) {
-	jsonData, err := json.Marshal(entry)
+	return l.Masking.Hex(msg)
+}
+
+// log builds a record with the service, the context id and the context attributes
+// and hands it to handler
+func (l *Logger) log(handler slog.Handler, c ctx.Context, level LogLevel, attrs ...slog.Attr) {
+	record := slog.NewRecord(time.Now(), level.slogLevel(), "", 0)
+
+	if l.Service != nil {
+		record.AddAttrs(slog.String("service", *l.Service))
+	}
+
+	if c != nil && c.GetId() != uuid.Nil {
+		record.AddAttrs(slog.String("id", c.GetId().String()))
+	}
+
+	record.AddAttrs(attrs...)
+
+	if c != nil {
+		record.AddAttrs(c.Attributes().Attrs()...)
+	}
+
+	err := handler.Handle(context.Background(), record)
 	if err != nil {
-		log.Fatalf("failed to marshal JSON: %v", err)
+		log.New(os.Stderr, "", 0).Printf("logger handler: %v", err)
 	}
-	log.Println(string(jsonData))
+}
+
+// rawJSON returns value as an embedded JSON document when it is valid JSON,
+// otherwise as a plain string
+func rawJSON(key string, value string) slog.Attr {
+	if json.Valid([]byte(value)) {
+		return slog.Any(key, json.RawMessage(value))
+	}
+
+	return slog.String(key, value)
 }
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tomasdemarco/iso8583/message"
+)
+
+// Outcome is the result of a transaction recorded in the access log
+type Outcome int
+
+const (
+	Approved Outcome = iota
+	Declined
+	Timeout
+	Failed
+	// NoResponse is a request the handler returned from without answering
+	NoResponse
+)
+
+var outcomeStrings = [...]string{
+	Approved:   "approved",
+	Declined:   "declined",
+	Timeout:    "timeout",
+	Failed:     "error",
+	NoResponse: "noResponse",
+}
+
+// ApprovedResponseCodes are the DE39 values considered approved
+var ApprovedResponseCodes = map[string]bool{
+	"00":  true,
+	"08":  true,
+	"10":  true,
+	"11":  true,
+	"000": true,
+	"001": true,
+	"800": true,
+}
+
+// OutcomeOf returns Approved when the response code (DE39) of msg is one of
+// ApprovedResponseCodes, otherwise Declined
+func OutcomeOf(msg *message.Message) Outcome {
+	if msg == nil {
+		return Failed
+	}
+
+	responseCode, err := msg.GetField(39)
+	if err == nil && ApprovedResponseCodes[responseCode] {
+		return Approved
+	}
+
+	return Declined
+}
+
+// String return string
+func (o *Outcome) String() string {
+	return outcomeStrings[*o]
+}
+
+// EnumIndex return index
+func (o *Outcome) EnumIndex() int {
+	return int(*o)
+}
+
+// MarshalJSON override default marshal json
+func (o Outcome) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+// UnmarshalJSON override default unmarshal json
+func (o *Outcome) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+
+	for i, str := range outcomeStrings {
+		if str == j {
+			*o = Outcome(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid outcome: %s", j)
+}
@@ -0,0 +1,266 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102-150405.000"
+
+// RotatingWriter writes to a file that is rotated when it reaches a maximum size
+// or when a time interval boundary is crossed. Rotated files are renamed to
+// path.<timestamp> and only the last MaxBackups are kept.
+type RotatingWriter struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	file       *os.File
+	size       int64
+	openedAt   time.Time
+	closed     bool
+}
+
+type RotatingOption func(*RotatingWriter)
+
+// WithMaxSize rotates the file when writing p would exceed size bytes
+func WithMaxSize(size int64) RotatingOption {
+	return func(w *RotatingWriter) {
+		w.maxSize = size
+	}
+}
+
+// WithRotationInterval rotates the file every time an interval boundary is crossed,
+// e.g. 24*time.Hour rotates at midnight UTC
+func WithRotationInterval(interval time.Duration) RotatingOption {
+	return func(w *RotatingWriter) {
+		w.interval = interval
+	}
+}
+
+// WithMaxBackups keeps only the last n rotated files, 0 keeps all of them
+func WithMaxBackups(n int) RotatingOption {
+	return func(w *RotatingWriter) {
+		w.maxBackups = n
+	}
+}
+
+func NewRotatingWriter(path string, opts ...RotatingOption) (*RotatingWriter, error) {
+	w := RotatingWriter{
+		path:    path,
+		maxSize: 100 * 1024 * 1024,
+	}
+
+	for _, opt := range opts {
+		opt(&w)
+	}
+
+	err := w.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// Write writes p to the current file, rotating it first when needed. The file is opened
+// again when a failed rotation left it closed.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	if w.file == nil {
+		err := w.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if w.shouldRotate(int64(len(p)), time.Now()) {
+		err := w.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Rotate forces a rotation of the current file
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if w.file == nil {
+		err := w.open()
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.rotate()
+}
+
+// Close closes the current file
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+func (w *RotatingWriter) shouldRotate(length int64, now time.Time) bool {
+	if w.size == 0 {
+		return false
+	}
+
+	if w.maxSize > 0 && w.size+length > w.maxSize {
+		return true
+	}
+
+	if w.interval > 0 && !now.Truncate(w.interval).Equal(w.openedAt.Truncate(w.interval)) {
+		return true
+	}
+
+	return false
+}
+
+func (w *RotatingWriter) open() error {
+	err := os.MkdirAll(filepath.Dir(w.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrOpenLogFile, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", ErrOpenLogFile, err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = info.ModTime()
+	if w.size == 0 {
+		w.openedAt = time.Now()
+	}
+
+	return nil
+}
+
+// rotate renames the current file to a backup and opens a new one. When the rename fails the
+// current file is opened again in append mode, so the writer keeps a usable file.
+func (w *RotatingWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return w.reopen(fmt.Errorf("%w: %w", ErrRotateLogFile, err))
+	}
+
+	backup := w.backupName(time.Now())
+
+	err = os.Rename(w.path, backup)
+	if err != nil {
+		return w.reopen(fmt.Errorf("%w: %w", ErrRotateLogFile, err))
+	}
+
+	err = w.open()
+	if err != nil {
+		return err
+	}
+
+	return w.removeBackups()
+}
+
+// reopen opens the current file again after a failed rotation and returns cause. When it
+// cannot be opened the file stays nil and the next write tries again.
+func (w *RotatingWriter) reopen(cause error) error {
+	err := w.open()
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+
+	return cause
+}
+
+// backupName returns the name of the backup rotated at now. The backups of the same millisecond
+// get a suffix above the ones already there, so the names stay sorted by age.
+func (w *RotatingWriter) backupName(now time.Time) string {
+	backup := fmt.Sprintf("%s.%s", w.path, now.Format(backupTimeFormat))
+
+	matches, _ := filepath.Glob(backup + "*")
+	if len(matches) == 0 {
+		return backup
+	}
+
+	last := 0
+	for _, match := range matches {
+		if n, err := strconv.Atoi(strings.TrimPrefix(match, backup+"-")); err == nil && n > last {
+			last = n
+		}
+	}
+
+	return fmt.Sprintf("%s-%03d", backup, last+1)
+}
+
+// removeBackups removes the oldest rotated files beyond maxBackups
+func (w *RotatingWriter) removeBackups() error {
+	if w.maxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return err
+	}
+
+	if len(backups) <= w.maxBackups {
+		return nil
+	}
+
+	sort.Strings(backups)
+
+	for _, backup := range backups[:len(backups)-w.maxBackups] {
+		err = os.Remove(backup)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrRotateLogFile, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+
+	return string(b)
+}
+
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+
+	return files
+}
+
+func write(t *testing.T, w *RotatingWriter, s string) {
+	t.Helper()
+
+	_, err := w.Write([]byte(s))
+	if err != nil {
+		t.Fatalf("write %q: %v", s, err)
+	}
+}
+
+func TestRotatingWriterMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+
+	w, err := NewRotatingWriter(path, WithMaxSize(10))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer w.Close()
+
+	write(t, w, "first\n")
+	write(t, w, "second\n")
+
+	if got := readFile(t, path); got != "second\n" {
+		t.Errorf("current file is %q, want %q", got, "second\n")
+	}
+
+	files := backups(t, path)
+	if len(files) != 1 {
+		t.Fatalf("backups %q, want 1", files)
+	}
+
+	if got := readFile(t, files[0]); got != "first\n" {
+		t.Errorf("backup is %q, want %q", got, "first\n")
+	}
+}
+
+func TestRotatingWriterMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	w, err := NewRotatingWriter(path, WithMaxBackups(2))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer w.Close()
+
+	for _, s := range []string{"1\n", "2\n", "3\n", "4\n"} {
+		write(t, w, s)
+
+		err = w.Rotate()
+		if err != nil {
+			t.Fatalf("rotate: %v", err)
+		}
+	}
+
+	files := backups(t, path)
+	if len(files) != 2 {
+		t.Fatalf("backups %q, want 2", files)
+	}
+
+	if got := readFile(t, files[0]) + readFile(t, files[1]); got != "3\n4\n" {
+		t.Errorf("backups hold %q, want the last two", got)
+	}
+}
+
+func TestRotatingWriterInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	w, err := NewRotatingWriter(path, WithMaxSize(0), WithRotationInterval(24*time.Hour))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer w.Close()
+
+	if w.shouldRotate(1, time.Now()) {
+		t.Errorf("an empty file is rotated")
+	}
+
+	write(t, w, "first\n")
+	w.openedAt = time.Date(2024, time.January, 1, 23, 59, 0, 0, time.UTC)
+
+	if w.shouldRotate(1, time.Date(2024, time.January, 1, 23, 59, 59, 0, time.UTC)) {
+		t.Errorf("rotated within the interval")
+	}
+
+	if !w.shouldRotate(1, time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("not rotated at the interval boundary")
+	}
+}
+
+// TestRotatingWriterRenameFailure checks that a failed rotation leaves a file to write to
+func TestRotatingWriterRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	w, err := NewRotatingWriter(path)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer w.Close()
+
+	write(t, w, "first\n")
+
+	// the rename of a removed file fails
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	err = w.Rotate()
+	if !errors.Is(err, ErrRotateLogFile) {
+		t.Fatalf("rotate returned %v, want %v", err, ErrRotateLogFile)
+	}
+
+	write(t, w, "second\n")
+
+	if got := readFile(t, path); got != "second\n" {
+		t.Errorf("file is %q, want %q", got, "second\n")
+	}
+}
+
+func TestRotatingWriterClosed(t *testing.T) {
+	w, err := NewRotatingWriter(filepath.Join(t.TempDir(), "app.log"))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	_, err = w.Write([]byte("late\n"))
+	if !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("write after close returned %v, want %v", err, ErrWriterClosed)
+	}
+
+	if !errors.Is(w.Rotate(), ErrWriterClosed) {
+		t.Errorf("rotate after close did not return %v", ErrWriterClosed)
+	}
+}
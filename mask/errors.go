@@ -0,0 +1,12 @@
+package mask
+
+import "errors"
+
+var (
+	ErrInvalidRule           = errors.New("invalid mask rule")
+	ErrInvalidDumpMode       = errors.New("invalid dump mode")
+	ErrInvalidFieldNumber    = errors.New("invalid field number in mask policy JSON")
+	ErrFailedToOpenFile      = errors.New("failed to open mask policy file")
+	ErrFailedToUnmarshalJSON = errors.New("failed to unmarshal mask policy JSON")
+	ErrInvalidTlv            = errors.New("invalid TLV data")
+)
@@ -0,0 +1,206 @@
+// Package mask applies PCI-DSS masking rules to ISO 8583 messages
+// before their content is written to logs.
+package mask
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tomasdemarco/iso8583/message"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Suppressed replaces a hex dump that can not be logged safely
+const Suppressed = "[suppressed]"
+
+// Policy defines the masking rule of each field and the EMV tags removed
+// from the fields with the Emv rule. Subfields holds the rules of the subfields
+// decoded in the logs, keyed like "126.10".
+type Policy struct {
+	Fields    map[int]Rule
+	Subfields map[string]Rule
+	EmvTags   []string
+	Dump      DumpMode
+}
+
+// PolicyDto represents the structure of a policy as defined in a JSON file
+type PolicyDto struct {
+	Fields    map[string]Rule `json:"fields"`
+	Subfields map[string]Rule `json:"subfields"`
+	EmvTags   []string        `json:"emvTags"`
+	Dump      DumpMode        `json:"dump"`
+}
+
+// DefaultPolicy masks the PAN, redacts track data, PIN blocks and the CVV2 of Visa DE126.10
+// and removes the EMV tags that carry track or cardholder data
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Fields: map[int]Rule{
+			2:  Pan,
+			34: Pan,
+			35: Track,
+			36: Track,
+			45: Track,
+			52: Redact,
+			55: Emv,
+		},
+		Subfields: map[string]Rule{
+			"126.10": Redact,
+		},
+		EmvTags: []string{"56", "57", "5A", "5F20", "9F1F", "9F20", "9F6B"},
+		Dump:    Repack,
+	}
+}
+
+// LoadFromJson loads a policy from a JSON file
+func LoadFromJson(path, file string) (*Policy, error) {
+	absPath, err := filepath.Abs(filepath.Join(path, file))
+	if err != nil {
+		return nil, err
+	}
+
+	byteValue, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToOpenFile, err)
+	}
+
+	var policyDto PolicyDto
+	err = json.Unmarshal(byteValue, &policyDto)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalJSON, err)
+	}
+
+	policy := Policy{
+		Fields:    make(map[int]Rule),
+		Subfields: policyDto.Subfields,
+		EmvTags:   policyDto.EmvTags,
+		Dump:      policyDto.Dump,
+	}
+
+	for k, v := range policyDto.Fields {
+		kNum, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFieldNumber, err)
+		}
+
+		policy.Fields[kNum] = v
+	}
+
+	return &policy, nil
+}
+
+// Value returns the masked value of a field and false if the field must not be logged
+func (p *Policy) Value(fieldId int, value string) (string, bool) {
+	return p.value(fieldId, value, '*')
+}
+
+// SubfieldValue returns the masked value of a subfield and false if the subfield must not be logged
+func (p *Policy) SubfieldValue(fieldId, subfieldId int, value string) (string, bool) {
+	return p.rule(p.Subfields[fmt.Sprintf("%d.%d", fieldId, subfieldId)], value, '*')
+}
+
+// Message returns a copy of msg with the policy applied, msg is not modified
+func (p *Policy) Message(msg *message.Message) *message.Message {
+	return p.message(msg, '*')
+}
+
+// Hex returns the hex dump to log for msg. The masked message is packed again with
+// the masked characters replaced by zeros, so the dump keeps the packager layout.
+// It returns Suppressed when the dump mode is Suppress or the masked message can not be packed.
+func (p *Policy) Hex(msg *message.Message) string {
+	if msg == nil || p.Dump == Suppress {
+		return Suppressed
+	}
+
+	msgRaw, err := p.message(msg, '0').Pack()
+	if err != nil {
+		return Suppressed
+	}
+
+	return fmt.Sprintf("%X", msgRaw)
+}
+
+func (p *Policy) message(msg *message.Message, maskChar byte) *message.Message {
+	masked := message.NewMessage(msg.Packager)
+	masked.Length = msg.Length
+	masked.Header = msg.Header
+	masked.Trailer = msg.Trailer
+
+	if mti, err := msg.GetField(0); err == nil {
+		masked.SetField(0, mti)
+	}
+
+	for _, fieldId := range msg.Bitmap.GetSliceString() {
+		if fieldId == 1 {
+			continue
+		}
+
+		value, err := msg.GetField(fieldId)
+		if err != nil {
+			continue
+		}
+
+		if value, ok := p.value(fieldId, value, maskChar); ok {
+			masked.SetField(fieldId, value)
+		}
+	}
+
+	return masked
+}
+
+func (p *Policy) value(fieldId int, value string, maskChar byte) (string, bool) {
+	return p.rule(p.Fields[fieldId], value, maskChar)
+}
+
+func (p *Policy) rule(rule Rule, value string, maskChar byte) (string, bool) {
+	switch rule {
+	case Pan:
+		return maskPan(value, maskChar), true
+	case Track:
+		return maskTrack(value, maskChar), true
+	case Redact:
+		return strings.Repeat(string(maskChar), len(value)), true
+	case Remove:
+		return "", false
+	case Emv:
+		tlv, err := removeTags(value, p.EmvTags)
+		if err != nil {
+			return strings.Repeat(string(maskChar), len(value)), true
+		}
+		return tlv, true
+	default:
+		return value, true
+	}
+}
+
+// maskPan keeps the first six and last four digits, shorter values are masked completely
+func maskPan(pan string, maskChar byte) string {
+	if len(pan) <= 10 {
+		return strings.Repeat(string(maskChar), len(pan))
+	}
+
+	return pan[:6] + strings.Repeat(string(maskChar), len(pan)-10) + pan[len(pan)-4:]
+}
+
+// maskTrack masks the PAN of a track and redacts everything after the field separator,
+// a leading format code (track 1 "B") is kept
+func maskTrack(track string, maskChar byte) string {
+	start := 0
+	for start < len(track) && (track[start] < '0' || track[start] > '9') {
+		start++
+	}
+
+	end := start
+	for end < len(track) && track[end] >= '0' && track[end] <= '9' {
+		end++
+	}
+
+	if end == len(track) {
+		return track[:start] + maskPan(track[start:], maskChar)
+	}
+
+	return track[:start] + maskPan(track[start:end], maskChar) + track[end:end+1] +
+		strings.Repeat(string(maskChar), len(track)-end-1)
+}
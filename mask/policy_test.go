@@ -0,0 +1,163 @@
+package mask
+
+import (
+	"errors"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	p := DefaultPolicy()
+
+	tests := []struct {
+		name    string
+		fieldId int
+		value   string
+		want    string
+	}{
+		{"PAN", 2, "4541234567890123", "454123******0123"},
+		{"short PAN", 2, "4541234567", "**********"},
+		{"track 2", 35, "4541234567890123=26121010000012300000", "454123******0123=********************"},
+		{"track 2 without separator", 35, "4541234567890123", "454123******0123"},
+		{"track 1", 45, "B4541234567890123^DOE/JOHN^2612101", "B454123******0123^****************"},
+		{"PIN block", 52, "0123456789ABCDEF", "****************"},
+		{"clear", 4, "000000001000", "000000001000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := p.Value(tt.fieldId, tt.value)
+			if !ok || got != tt.want {
+				t.Errorf("value %q is %q, %v, want %q", tt.value, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueRemove(t *testing.T) {
+	p := &Policy{Fields: map[int]Rule{48: Remove}}
+
+	if _, ok := p.Value(48, "secret"); ok {
+		t.Errorf("a removed field is logged")
+	}
+}
+
+func TestValueEmv(t *testing.T) {
+	p := DefaultPolicy()
+
+	// 9F02 amount, 9F27 cryptogram information, 5A PAN and 5F20 cardholder name
+	tlv := "9F0206000000001000" + "9F270180" + "5A084541234567890123" + "5F200A444F452F4A4F484E2020"
+
+	got, ok := p.Value(55, tlv)
+	if !ok || got != "9F0206000000001000"+"9F270180" {
+		t.Errorf("EMV data is %q, want the PAN and cardholder name removed", got)
+	}
+
+	got, ok = p.Value(55, "9F02FF00")
+	if !ok || got != "********" {
+		t.Errorf("invalid EMV data is %q, want it redacted", got)
+	}
+}
+
+func TestSubfieldValue(t *testing.T) {
+	p := DefaultPolicy()
+
+	if got, _ := p.SubfieldValue(126, 10, "123"); got != "***" {
+		t.Errorf("CVV2 is %q, want it redacted", got)
+	}
+
+	if got, _ := p.SubfieldValue(126, 9, "123"); got != "123" {
+		t.Errorf("subfield 126.9 is %q, want it clear", got)
+	}
+}
+
+func newMessage(t *testing.T) *message.Message {
+	t.Helper()
+
+	pkg, err := packager.LoadFromJson("../iso8583/packager", "iso87BPackager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	msg := message.NewMessage(pkg)
+	msg.SetField(0, "0200")
+	msg.SetField(2, "4541234567890123")
+	msg.SetField(3, "000000")
+	msg.SetField(4, "000000001000")
+	msg.SetField(35, "4541234567890123=2612101")
+
+	return msg
+}
+
+func TestMessage(t *testing.T) {
+	msg := newMessage(t)
+
+	masked := DefaultPolicy().Message(msg)
+
+	if masked.Fields[2] != "454123******0123" || masked.Fields[35] != "454123******0123=*******" || masked.Fields[4] != "000000001000" {
+		t.Errorf("masked fields %v", masked.Fields)
+	}
+
+	if msg.Fields[2] != "4541234567890123" {
+		t.Errorf("the message was modified")
+	}
+}
+
+func TestHex(t *testing.T) {
+	msg := newMessage(t)
+
+	p := DefaultPolicy()
+	hex := p.Hex(msg)
+	if hex == Suppressed || strings.Contains(hex, "4541234567890123") || !strings.Contains(hex, "4541230000000123") {
+		t.Errorf("hex dump %s, want the PAN masked with zeros", hex)
+	}
+
+	p.Dump = Suppress
+	if got := p.Hex(msg); got != Suppressed {
+		t.Errorf("hex dump %s in suppress mode", got)
+	}
+
+	if got := DefaultPolicy().Hex(nil); got != Suppressed {
+		t.Errorf("hex dump %s of a nil message", got)
+	}
+}
+
+func TestLoadFromJson(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(file, content string) {
+		err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	write("policy.json", `{"fields":{"2":"PAN","48":"REMOVE"},"subfields":{"126.10":"REDACT"},"emvTags":["5A"],"dump":"SUPPRESS"}`)
+
+	p, err := LoadFromJson(dir, "policy.json")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if p.Fields[2] != Pan || p.Fields[48] != Remove || p.Subfields["126.10"] != Redact || p.Dump != Suppress || len(p.EmvTags) != 1 {
+		t.Errorf("policy %+v", p)
+	}
+
+	write("rule.json", `{"fields":{"2":"HIDE"}}`)
+	if _, err = LoadFromJson(dir, "rule.json"); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("load returned %v, want %v", err, ErrInvalidRule)
+	}
+
+	write("field.json", `{"fields":{"PAN":"PAN"}}`)
+	if _, err = LoadFromJson(dir, "field.json"); !errors.Is(err, ErrInvalidFieldNumber) {
+		t.Errorf("load returned %v, want %v", err, ErrInvalidFieldNumber)
+	}
+
+	if _, err = LoadFromJson(dir, "missing.json"); !errors.Is(err, ErrFailedToOpenFile) {
+		t.Errorf("load returned %v, want %v", err, ErrFailedToOpenFile)
+	}
+}
@@ -0,0 +1,101 @@
+package mask
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Rule defines how the value of a field is masked before it is logged
+type Rule int
+
+const (
+	Clear Rule = iota
+	Pan
+	Track
+	Redact
+	Remove
+	Emv
+)
+
+var ruleStrings = [...]string{
+	Clear:  "CLEAR",
+	Pan:    "PAN",
+	Track:  "TRACK",
+	Redact: "REDACT",
+	Remove: "REMOVE",
+	Emv:    "EMV",
+}
+
+// String return string
+func (r *Rule) String() string {
+	return ruleStrings[*r]
+}
+
+// EnumIndex return index
+func (r *Rule) EnumIndex() int {
+	return int(*r)
+}
+
+// UnmarshalJSON override default unmarshal json
+func (r *Rule) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+
+	for i, str := range ruleStrings {
+		if str == j {
+			*r = Rule(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidRule, j)
+}
+
+func (r *Rule) IsValid() bool {
+	if int(*r) >= 0 && int(*r) < len(ruleStrings) {
+		value := ruleStrings[*r]
+		if value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// DumpMode defines how raw hex dumps are logged when a policy is applied
+type DumpMode int
+
+const (
+	Repack DumpMode = iota
+	Suppress
+)
+
+var dumpModeStrings = [...]string{
+	Repack:   "REPACK",
+	Suppress: "SUPPRESS",
+}
+
+// String return string
+func (d *DumpMode) String() string {
+	return dumpModeStrings[*d]
+}
+
+// UnmarshalJSON override default unmarshal json
+func (d *DumpMode) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+
+	for i, str := range dumpModeStrings {
+		if str == j {
+			*d = DumpMode(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidDumpMode, j)
+}
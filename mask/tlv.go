@@ -0,0 +1,16 @@
+package mask
+
+import (
+	"fmt"
+	"github.com/tomasdemarco/go-pos/emv"
+)
+
+// removeTags drops the given tags from the BER-TLV data in value (hex)
+func removeTags(value string, tags []string) (string, error) {
+	l, err := emv.ParseHex(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidTlv, err)
+	}
+
+	return l.Remove(tags...).Hex()
+}
@@ -0,0 +1,9 @@
+package mti
+
+import "errors"
+
+var (
+	ErrInvalidMti      = errors.New("invalid mti")
+	ErrAlreadyResponse = errors.New("mti is already a response")
+	ErrNoResponse      = errors.New("mti has no response")
+)
@@ -0,0 +1,66 @@
+// Package mti maps the message type indicators of ISO 8583 requests to the ones of their responses.
+//
+// The digits of an MTI are version (0 = 1987, 1 = 1993, 2 = 2003), class, function and origin.
+// A response has the next function (request 0 -> response 1, advice 2 -> 3, notification 4 -> 5,
+// instruction 6 -> 7) and the origin of the request without the repeat flag, e.g. 0200 -> 0210,
+// 0201 -> 0210, 0421 -> 0430 and 1804 -> 1814.
+package mti
+
+import "fmt"
+
+// Response returns the MTI of the response to the request MTI
+func Response(mti string) (string, error) {
+	err := validate(mti)
+	if err != nil {
+		return "", err
+	}
+
+	function := mti[2] - '0'
+	switch {
+	case function > 7: // reserved for ISO use
+		return "", fmt.Errorf("%w: %s", ErrNoResponse, mti)
+	case function%2 == 1:
+		return "", fmt.Errorf("%w: %s", ErrAlreadyResponse, mti)
+	}
+
+	origin := mti[3] - '0'
+	if origin <= 5 {
+		origin -= origin % 2
+	}
+
+	return fmt.Sprintf("%s%d%d", mti[:2], function+1, origin), nil
+}
+
+// IsResponse reports whether mti is the MTI of a response, e.g. 0210 or 0430
+func IsResponse(mti string) bool {
+	return validate(mti) == nil && (mti[2]-'0')%2 == 1 && mti[2] <= '7'
+}
+
+// IsReversal reports whether mti is of the reversal class, e.g. 0400, 0420 or 0410
+func IsReversal(mti string) bool {
+	return validate(mti) == nil && mti[1] == '4'
+}
+
+// Original returns mti without the repeat flag of its origin, e.g. 0201 -> 0200
+func Original(mti string) string {
+	if validate(mti) != nil || mti[3] > '5' {
+		return mti
+	}
+
+	return mti[:3] + string(mti[3]-(mti[3]-'0')%2)
+}
+
+// validate returns an error when mti is not four digits
+func validate(mti string) error {
+	if len(mti) != 4 {
+		return fmt.Errorf("%w: %q", ErrInvalidMti, mti)
+	}
+
+	for i := 0; i < len(mti); i++ {
+		if mti[i] < '0' || mti[i] > '9' {
+			return fmt.Errorf("%w: %q", ErrInvalidMti, mti)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package mti
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	tests := []struct {
+		mti  string
+		want string
+	}{
+		{"0100", "0110"},
+		{"0200", "0210"},
+		{"0201", "0210"},
+		{"0220", "0230"},
+		{"0221", "0230"},
+		{"0400", "0410"},
+		{"0420", "0430"},
+		{"0421", "0430"},
+		{"0800", "0810"},
+		{"1804", "1814"},
+		{"1805", "1814"},
+		{"0206", "0216"},
+		{"0640", "0650"},
+	}
+
+	for _, tt := range tests {
+		got, err := Response(tt.mti)
+		if err != nil || got != tt.want {
+			t.Errorf("response of %s is %q, %v, want %s", tt.mti, got, err, tt.want)
+		}
+	}
+}
+
+func TestResponseRefused(t *testing.T) {
+	tests := []struct {
+		mti  string
+		want error
+	}{
+		{"0210", ErrAlreadyResponse},
+		{"0430", ErrAlreadyResponse},
+		{"0280", ErrNoResponse},
+		{"0290", ErrNoResponse},
+		{"", ErrInvalidMti},
+		{"200", ErrInvalidMti},
+		{"02A0", ErrInvalidMti},
+		{"02000", ErrInvalidMti},
+	}
+
+	for _, tt := range tests {
+		got, err := Response(tt.mti)
+		if !errors.Is(err, tt.want) || got != "" {
+			t.Errorf("response of %q is %q, %v, want %v", tt.mti, got, err, tt.want)
+		}
+	}
+}
+
+func TestIsResponse(t *testing.T) {
+	for mti, want := range map[string]bool{"0210": true, "0430": true, "0200": false, "0290": false, "021": false} {
+		if got := IsResponse(mti); got != want {
+			t.Errorf("is response %q is %v, want %v", mti, got, want)
+		}
+	}
+}
+
+func TestIsReversal(t *testing.T) {
+	for mti, want := range map[string]bool{"0400": true, "0420": true, "0410": true, "0200": false, "04": false} {
+		if got := IsReversal(mti); got != want {
+			t.Errorf("is reversal %q is %v, want %v", mti, got, want)
+		}
+	}
+}
+
+func TestOriginal(t *testing.T) {
+	for mti, want := range map[string]string{"0201": "0200", "0421": "0420", "0200": "0200", "0206": "0206", "x": "x"} {
+		if got := Original(mti); got != want {
+			t.Errorf("original of %q is %q, want %q", mti, got, want)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package proxy
+
+import "errors"
+
+var (
+	ErrOriginalNotFound = errors.New("original request not found")
+)
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"github.com/tomasdemarco/go-pos/mti"
+	"github.com/tomasdemarco/iso8583/message"
+	"strings"
+	"time"
+)
+
+// Mapping links a request received by the server to the request forwarded upstream, to route
+// its late response and its reversals
+type Mapping struct {
+	// Key identifies the request among the requests of the server, by the key fields of the proxy
+	Key string
+	// UpstreamKey is the id the client matches the upstream response with
+	UpstreamKey string
+	Request     *message.Message
+	Upstream    *message.Message
+	// Response is the upstream response, nil until it arrives
+	Response *message.Message
+	Time     time.Time
+}
+
+// Original returns the mapping of the request that req reverses, or of req itself when it is not
+// a reversal. A reversal finds its original by the STAN of DE90 when it has one, or else by its own
+// key fields, as the terminals that repeat the STAN of the original send it.
+func (p *Proxy) Original(req *message.Message) (*Mapping, bool) {
+	key := p.key(req)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.byKey[key]
+	if !ok {
+		return nil, false
+	}
+
+	original := *m
+	return &original, true
+}
+
+// add keeps the mapping of req forwarded as upstream. Reversals are only kept by their upstream
+// key, so that a repeated reversal still finds the original request.
+func (p *Proxy) add(req, upstream *message.Message) *Mapping {
+	m := Mapping{
+		Key:         p.key(req),
+		UpstreamKey: p.upstreamKey(upstream),
+		Request:     req,
+		Upstream:    upstream,
+		Time:        time.Now(),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prune(m.Time)
+
+	if !isReversal(req) {
+		p.byKey[m.Key] = &m
+	}
+	p.byUpstream[m.UpstreamKey] = &m
+
+	return &m
+}
+
+// respond records response as the response of the mapping of upstreamKey. It returns false when
+// the mapping is unknown or already has a response.
+func (p *Proxy) respond(upstreamKey string, response *message.Message) (Mapping, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.byUpstream[upstreamKey]
+	if !ok || m.Response != nil {
+		return Mapping{}, false
+	}
+
+	m.Response = response
+
+	return *m, true
+}
+
+// prune removes the mappings older than the TTL, at most every tenth of the TTL
+func (p *Proxy) prune(now time.Time) {
+	if now.Sub(p.pruned) < p.ttl/10 {
+		return
+	}
+	p.pruned = now
+
+	for key, m := range p.byKey {
+		if now.Sub(m.Time) > p.ttl {
+			delete(p.byKey, key)
+		}
+	}
+
+	for key, m := range p.byUpstream {
+		if now.Sub(m.Time) > p.ttl {
+			delete(p.byUpstream, key)
+		}
+	}
+}
+
+// key returns the values of the key fields of req. The STAN of a reversal is the one of DE90,
+// the original data elements, when it has them.
+func (p *Proxy) key(req *message.Message) string {
+	values := make([]string, len(p.keyFields))
+	for i, fieldId := range p.keyFields {
+		values[i] = req.Fields[fieldId]
+
+		if fieldId == 11 && isReversal(req) && len(req.Fields[90]) >= 10 {
+			values[i] = req.Fields[90][4:10]
+		}
+	}
+
+	return strings.Join(values, "|")
+}
+
+// upstreamKey returns the id the client matches the response to upstream with: the values of its
+// match fields, with the MTI of the response for field 0
+func (p *Proxy) upstreamKey(upstream *message.Message) string {
+	var key string
+	for _, fieldId := range p.Client.MatchFields {
+		value := upstream.Fields[fieldId]
+		if fieldId == 0 {
+			value, _ = mti.Response(value)
+		}
+		key += value
+	}
+
+	return key
+}
+
+// responseKey returns the id of response as the client computes it
+func (p *Proxy) responseKey(response *message.Message) string {
+	var key string
+	for _, fieldId := range p.Client.MatchFields {
+		key += response.Fields[fieldId]
+	}
+
+	return key
+}
+
+func isReversal(msg *message.Message) bool {
+	return mti.IsReversal(msg.Fields[0])
+}
@@ -0,0 +1,317 @@
+// Package proxy forwards the requests received by a server to an upstream client and answers
+// them with the upstream response, as a switch between terminals and a host.
+//
+// The forwarded request gets a new STAN, DE7 and DE37 from the stamp policy of the proxy and the
+// response gets the ones of the original request back. A mapping of the original and upstream keys
+// routes the responses that arrive after their timeout to the late function, and gives the reversals
+// the upstream data of the request they reverse.
+//
+//	upstream := client.New("10.0.0.2", 9000, visaPackager)
+//	forwarder := proxy.New(upstream, proxy.WithRequestFunc(addAcquirer))
+//	srv := server.New(8015, posPackager, forwarder.Handle)
+package proxy
+
+import (
+	"fmt"
+	"github.com/tomasdemarco/go-pos/client"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"sync"
+	"time"
+)
+
+// Proxy forwards the requests of a server with Client and answers with the mapped upstream response
+type Proxy struct {
+	Client *client.Client
+
+	// RequestFunc transforms the upstream request before it is sent. original is the mapping of the
+	// request that a reversal reverses, nil for the other requests.
+	RequestFunc func(c *ctx.RequestContext, upstream *message.Message, original *Mapping) error
+	// ResponseFunc transforms the response before it is sent to the server client. upstream is nil
+	// when the response is the failure response.
+	ResponseFunc func(c *ctx.RequestContext, upstream, response *message.Message) error
+	// LateFunc is called with the responses that arrive after the timeout of their request, e.g.
+	// to reverse an approval the terminal never got
+	LateFunc func(m Mapping, response *message.Message)
+
+	stampPolicy         *client.StampPolicy
+	restore             []int
+	keyFields           []int
+	failureResponseCode string
+	ttl                 time.Duration
+	unmatched           client.HandlerFunc
+
+	mu         sync.Mutex
+	byKey      map[string]*Mapping
+	byUpstream map[string]*Mapping
+	pruned     time.Time
+}
+
+type Option func(*Proxy)
+
+// WithRequestFunc transforms every upstream request with f before it is sent
+func WithRequestFunc(f func(c *ctx.RequestContext, upstream *message.Message, original *Mapping) error) Option {
+	return func(p *Proxy) {
+		p.RequestFunc = f
+	}
+}
+
+// WithResponseFunc transforms every response with f before it is sent to the server client
+func WithResponseFunc(f func(c *ctx.RequestContext, upstream, response *message.Message) error) Option {
+	return func(p *Proxy) {
+		p.ResponseFunc = f
+	}
+}
+
+// WithLateFunc calls f with the upstream responses that arrive after the timeout of their request
+func WithLateFunc(f func(m Mapping, response *message.Message)) Option {
+	return func(p *Proxy) {
+		p.LateFunc = f
+	}
+}
+
+// WithStampPolicy sets the trace fields that the upstream requests get anew, DE7, DE11 and DE37
+// by default. The fields of the policy are removed from the request before it is stamped.
+func WithStampPolicy(policy *client.StampPolicy) Option {
+	return func(p *Proxy) {
+		p.stampPolicy = policy
+	}
+}
+
+// WithRestore sets the fields of the request that replace the upstream ones in the response,
+// DE7, DE11 and DE37 by default
+func WithRestore(fields ...int) Option {
+	return func(p *Proxy) {
+		p.restore = fields
+	}
+}
+
+// WithKeyFields sets the fields that identify a request among the requests of the server,
+// DE41 and DE11 by default
+func WithKeyFields(fields ...int) Option {
+	return func(p *Proxy) {
+		p.keyFields = fields
+	}
+}
+
+// WithFailureResponseCode sets the response code of the answer to the requests that could not be
+// forwarded or timed out, 91 (issuer or switch inoperative) by default
+func WithFailureResponseCode(responseCode string) Option {
+	return func(p *Proxy) {
+		p.failureResponseCode = responseCode
+	}
+}
+
+// WithTTL sets how long the mappings are kept for late responses and reversals, 10 minutes by default
+func WithTTL(ttl time.Duration) Option {
+	return func(p *Proxy) {
+		p.ttl = ttl
+	}
+}
+
+// New returns a proxy that forwards with cli. It takes the messages of cli that match no ongoing
+// transaction to find the late responses, the other ones go to the previous unmatched function of cli.
+func New(cli *client.Client, opts ...Option) *Proxy {
+	p := Proxy{
+		Client: cli,
+		stampPolicy: &client.StampPolicy{
+			Fields:   []int{7, 11, 37},
+			Now:      time.Now,
+			Location: time.Local,
+			RrnFunc:  client.Rrn,
+		},
+		restore:             []int{7, 11, 37},
+		keyFields:           []int{41, 11},
+		failureResponseCode: "91",
+		ttl:                 10 * time.Minute,
+		unmatched:           cli.UnmatchedFunc,
+		byKey:               make(map[string]*Mapping),
+		byUpstream:          make(map[string]*Mapping),
+	}
+
+	for _, opt := range opts {
+		opt(&p)
+	}
+
+	cli.UnmatchedFunc = p.handleUnmatched
+
+	return &p
+}
+
+// Handle is the handler function of a server that forwards the request upstream and answers with
+// the upstream response, or with the failure response code when it fails or times out
+func (p *Proxy) Handle(c *ctx.RequestContext, srv *server.Server) {
+	upstreamRes, err := p.forward(c)
+	if err != nil {
+		srv.Logger.Error(c, fmt.Errorf("forward: %w", err))
+	}
+
+	var response *message.Message
+	if upstreamRes != nil {
+		response = p.response(c.Request, upstreamRes)
+	} else {
+		response, err = srv.NewResponse(c.Request)
+		if err != nil {
+			srv.Logger.Error(c, err)
+			return
+		}
+		response.SetField(39, p.failureResponseCode)
+	}
+
+	if p.ResponseFunc != nil {
+		err = p.ResponseFunc(c, upstreamRes, response)
+		if err != nil {
+			srv.Logger.Error(c, fmt.Errorf("response func: %w", err))
+			return
+		}
+		response = rebuild(response, response.Packager)
+	}
+
+	err = srv.SendResponse(c, response)
+	if err != nil {
+		srv.Logger.Error(c, fmt.Errorf("error trying to send response message to the client: %w", err))
+	}
+}
+
+// forward sends the request of c upstream and waits for its response
+func (p *Proxy) forward(c *ctx.RequestContext) (*message.Message, error) {
+	upstream := message.NewMessage(p.upstreamPackager())
+	for fieldId, value := range c.Request.Fields {
+		if _, ok := upstream.Packager.Fields[fieldId]; ok && fieldId != 1 && !p.stamped(fieldId) {
+			upstream.SetField(fieldId, value)
+		}
+	}
+
+	err := p.stampPolicy.Stamp(upstream, p.Client.Stan)
+	if err != nil {
+		return nil, err
+	}
+
+	var original *Mapping
+	if isReversal(c.Request) {
+		m, ok := p.Original(c.Request)
+		if ok {
+			original = m
+			reverse(upstream, original)
+		} else {
+			p.Client.Logger.Warn(c, fmt.Sprintf("%v: %s, forwarding the reversal as is", ErrOriginalNotFound, p.key(c.Request)))
+		}
+	}
+
+	if p.RequestFunc != nil {
+		err = p.RequestFunc(c, upstream, original)
+		if err != nil {
+			return nil, fmt.Errorf("request func: %w", err)
+		}
+		upstream = rebuild(upstream, upstream.Packager)
+	}
+
+	upstreamCtx := ctx.NewChildRequestContext(c, upstream)
+
+	err = p.Client.Send(upstreamCtx, upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	m := p.add(c.Request, upstream)
+
+	upstreamRes, err := p.Client.Wait(upstreamCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.respond(m.UpstreamKey, upstreamRes)
+
+	return upstreamRes, nil
+}
+
+// response maps the upstream response to the response to req: the fields of the upstream response
+// that the packager of req defines, with the restored fields of req
+func (p *Proxy) response(req, upstreamRes *message.Message) *message.Message {
+	response := rebuild(upstreamRes, req.Packager)
+	response.Header = req.Header
+	response.Trailer = req.Trailer
+
+	for _, fieldId := range p.restore {
+		if value, ok := req.Fields[fieldId]; ok {
+			response.SetField(fieldId, value)
+		}
+	}
+
+	return response
+}
+
+// handleUnmatched routes the late upstream responses to LateFunc
+func (p *Proxy) handleUnmatched(c *ctx.RequestContext, cli *client.Client) {
+	m, ok := p.respond(p.responseKey(c.Request), c.Request)
+	if !ok {
+		if p.unmatched != nil {
+			p.unmatched(c, cli)
+		}
+		return
+	}
+
+	cli.Logger.Warn(c, fmt.Sprintf("late response %s to request %s", m.UpstreamKey, m.Key))
+
+	if p.LateFunc != nil {
+		p.LateFunc(m, c.Request)
+	}
+}
+
+// stamped reports whether fieldId is set by the stamp policy
+func (p *Proxy) stamped(fieldId int) bool {
+	for _, v := range p.stampPolicy.Fields {
+		if v == fieldId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// upstreamPackager returns the packager of the upstream requests, the current one of the client
+func (p *Proxy) upstreamPackager() *packager.Packager {
+	if p.Client.PackagerFunc != nil {
+		if pkg := p.Client.PackagerFunc(); pkg != nil {
+			return pkg
+		}
+	}
+
+	return p.Client.Packager
+}
+
+// reverse gives the upstream reversal the upstream data of the original request: its retrieval
+// reference number (DE37) and, in the original data elements (DE90), its STAN and DE7
+func reverse(upstream *message.Message, original *Mapping) {
+	rrn, ok := original.Upstream.Fields[37]
+	if original.Response != nil {
+		if value, found := original.Response.Fields[37]; found {
+			rrn, ok = value, true
+		}
+	}
+	if ok {
+		upstream.SetField(37, rrn)
+	}
+
+	stan, transmission := original.Upstream.Fields[11], original.Upstream.Fields[7]
+	if de90, ok := upstream.Fields[90]; ok && len(de90) >= 20 && len(stan) == 6 && len(transmission) == 10 {
+		upstream.SetField(90, de90[:4]+stan+transmission+de90[20:])
+	}
+}
+
+// rebuild returns a new message of pkg with the fields of msg that pkg defines.
+// The bitmap of a message keeps the removed fields, so the result is a new message.
+func rebuild(msg *message.Message, pkg *packager.Packager) *message.Message {
+	result := message.NewMessage(pkg)
+	result.Header = msg.Header
+	result.Trailer = msg.Trailer
+	for fieldId, value := range msg.Fields {
+		if _, ok := pkg.Fields[fieldId]; ok && fieldId != 1 {
+			result.SetField(fieldId, value)
+		}
+	}
+
+	return result
+}
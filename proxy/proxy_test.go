@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"github.com/tomasdemarco/go-pos/client"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"github.com/tomasdemarco/iso8583/utils"
+	"io"
+	"testing"
+	"time"
+)
+
+// loadPackager loads a new packager on every call, as the library keeps decoding state in it:
+// each side of the test unpacks with its own
+func loadPackager(t *testing.T, file string) *packager.Packager {
+	t.Helper()
+
+	pkg, err := subfield.LoadFromJson("../iso8583/packager", file)
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+func newProxy(t *testing.T, opts ...Option) *Proxy {
+	t.Helper()
+
+	cli := client.New("pipe", 0, loadPackager(t, "iso87BVisaBase1Packager.json"),
+		client.WithLogger(logger.New(logger.Info, "upstream", logger.WithWriter(io.Discard))),
+	)
+
+	return New(cli, opts...)
+}
+
+func TestKey(t *testing.T) {
+	pkg := loadPackager(t, "iso87BVisaBase1Packager.json")
+	p := newProxy(t)
+
+	request := gopostest.NewMessage(pkg, map[int]string{0: "0200", 11: "000123", 41: "00000001"})
+	if key := p.key(request); key != "00000001|000123" {
+		t.Errorf("key %q", key)
+	}
+
+	// a reversal is keyed by the STAN of the original data elements
+	reversal := gopostest.NewMessage(pkg, map[int]string{0: "0420", 11: "000124", 41: "00000001", 90: "020000012301011200000000000000000000000000"})
+	if key := p.key(reversal); key != "00000001|000123" {
+		t.Errorf("key of the reversal %q", key)
+	}
+
+	// or by its own STAN when it has no DE90
+	delete(reversal.Fields, 90)
+	if key := p.key(reversal); key != "00000001|000124" {
+		t.Errorf("key of the reversal without DE90 %q", key)
+	}
+
+	upstream := gopostest.NewMessage(pkg, map[int]string{0: "0200", 7: "0101120000", 11: "000500"})
+	response := gopostest.NewMessage(pkg, map[int]string{0: "0210", 7: "0101120000", 11: "000500"})
+	if p.upstreamKey(upstream) != p.responseKey(response) {
+		t.Errorf("upstream key %q does not match the response key %q", p.upstreamKey(upstream), p.responseKey(response))
+	}
+}
+
+func TestMapping(t *testing.T) {
+	pkg := loadPackager(t, "iso87BVisaBase1Packager.json")
+	p := newProxy(t, WithTTL(time.Minute))
+
+	request := gopostest.NewMessage(pkg, map[int]string{0: "0200", 11: "000123", 41: "00000001"})
+	upstream := gopostest.NewMessage(pkg, map[int]string{0: "0200", 7: "0101120000", 11: "000500", 37: "400112000500"})
+	m := p.add(request, upstream)
+
+	reversal := gopostest.NewMessage(pkg, map[int]string{0: "0420", 11: "000123", 41: "00000001"})
+	original, ok := p.Original(reversal)
+	if !ok || original.Upstream != upstream {
+		t.Fatalf("the reversal did not find its original")
+	}
+
+	// the reversal is not kept by key, a repeated reversal still finds the original
+	p.add(reversal, gopostest.NewMessage(pkg, map[int]string{0: "0420", 7: "0101120100", 11: "000501"}))
+	if original, ok = p.Original(reversal); !ok || original.Upstream != upstream {
+		t.Errorf("the repeated reversal did not find its original")
+	}
+
+	response := gopostest.NewMessage(pkg, map[int]string{0: "0210", 37: "400112999999", 39: "00"})
+	if _, ok = p.respond(m.UpstreamKey, response); !ok {
+		t.Fatalf("the response was not recorded")
+	}
+
+	if _, ok = p.respond(m.UpstreamKey, response); ok {
+		t.Errorf("a second response was recorded")
+	}
+
+	if _, ok = p.respond("unknown", response); ok {
+		t.Errorf("a response of an unknown request was recorded")
+	}
+
+	// the upstream reversal gets the data of the original upstream request and its response
+	original, _ = p.Original(reversal)
+	upstreamReversal := gopostest.NewMessage(pkg, map[int]string{0: "0420", 90: "020000012301011200000000000000000000000000"})
+	reverse(upstreamReversal, original)
+
+	if upstreamReversal.Fields[37] != "400112999999" || upstreamReversal.Fields[90] != "020000050001011200000000000000000000000000" {
+		t.Errorf("upstream reversal %v", upstreamReversal.Fields)
+	}
+
+	// the mappings are removed after the TTL
+	p.mu.Lock()
+	p.prune(time.Now().Add(2 * time.Minute))
+	p.mu.Unlock()
+
+	if _, ok = p.Original(reversal); ok {
+		t.Errorf("the mapping was kept after the TTL")
+	}
+}
+
+func approve(c *ctx.RequestContext, srv *server.Server) {
+	response, err := srv.NewResponse(c.Request)
+	if err != nil {
+		return
+	}
+
+	response.SetField(39, "00")
+	_ = srv.SendResponse(c, response)
+}
+
+// forward starts a host with handler and a proxy to it, and returns the harness of the proxy
+func forward(t *testing.T, handler server.HandlerFunc, opts ...Option) *gopostest.Harness {
+	t.Helper()
+
+	host := gopostest.New(t, loadPackager(t, "iso87BVisaBase1Packager.json"), handler)
+
+	cli := client.New("pipe", 0, loadPackager(t, "iso87BVisaBase1Packager.json"),
+		client.WithDialer(host.Listener.Dial),
+		client.WithAutoReconnect(false),
+		client.WithTimeout(200*time.Millisecond),
+		client.WithStan(utils.NewStan(500, 999999)),
+		client.WithLogger(logger.New(logger.Info, "upstream", logger.WithWriter(io.Discard))),
+	)
+
+	p := New(cli, opts...)
+
+	err := cli.Connect()
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	h := gopostest.New(t, loadPackager(t, "iso87BVisaBase1Packager.json"), p.Handle)
+
+	// the proxy disconnects before the harnesses close and check their goroutines
+	t.Cleanup(func() {
+		_ = cli.Disconnect()
+	})
+
+	return h
+}
+
+func TestProxy(t *testing.T) {
+	upstreams := make(chan *message.Message, 1)
+
+	h := forward(t, func(c *ctx.RequestContext, srv *server.Server) {
+		upstreams <- c.Request
+		approve(c, srv)
+	})
+
+	request := h.Message(map[int]string{0: "0200", 3: "000000", 4: "000000001000", 37: "400101000001", 41: "00000001"})
+	response, err := h.Do(request)
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+
+	upstream := <-upstreams
+	if upstream.Fields[11] == request.Fields[11] || upstream.Fields[37] == request.Fields[37] {
+		t.Errorf("the upstream request has the STAN %s and RRN %s of the request", upstream.Fields[11], upstream.Fields[37])
+	}
+
+	gopostest.AssertResponseCode(t, response, "00")
+	gopostest.AssertFields(t, response, map[int]string{7: request.Fields[7], 11: request.Fields[11], 37: request.Fields[37]})
+}
+
+func TestLateResponse(t *testing.T) {
+	release := make(chan struct{})
+	late := make(chan Mapping, 1)
+
+	h := forward(t, func(c *ctx.RequestContext, srv *server.Server) {
+		<-release
+		approve(c, srv)
+	}, WithLateFunc(func(m Mapping, response *message.Message) {
+		late <- m
+	}))
+
+	request := h.Message(map[int]string{0: "0200", 3: "000000", 4: "000000001000", 41: "00000001"})
+	response, err := h.Do(request)
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+
+	// the terminal gets the failure response when the host does not answer in time
+	gopostest.AssertResponseCode(t, response, "91")
+	close(release)
+
+	select {
+	case m := <-late:
+		if m.Key != "00000001|"+request.Fields[11] || m.Response == nil || m.Response.Fields[39] != "00" {
+			t.Errorf("late response of %s: %v", m.Key, m.Response)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("the late response was not routed to the late function")
+	}
+}
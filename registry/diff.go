@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"fmt"
+	"github.com/tomasdemarco/iso8583/packager"
+	"github.com/tomasdemarco/iso8583/padding"
+	"github.com/tomasdemarco/iso8583/prefix"
+	"sort"
+	"strconv"
+)
+
+// PrefixFieldId is the FieldId of the changes of the length prefix of the messages
+const PrefixFieldId = -1
+
+// Change is a difference between two versions of a packager. Attribute is empty when
+// the field was added (Old is empty) or removed (New is empty).
+type Change struct {
+	FieldId   int
+	Attribute string
+	Old       string
+	New       string
+}
+
+func (c Change) String() string {
+	name := fmt.Sprintf("field %d", c.FieldId)
+	if c.FieldId == PrefixFieldId {
+		name = "prefix"
+	}
+
+	switch {
+	case c.Attribute != "":
+		return fmt.Sprintf("%s %s: %s -> %s", name, c.Attribute, c.Old, c.New)
+	case c.Old == "":
+		return fmt.Sprintf("%s added: %s", name, c.New)
+	default:
+		return fmt.Sprintf("%s removed: %s", name, c.Old)
+	}
+}
+
+// Diff returns the changes from old to new ordered by field id, the prefix first
+func Diff(old, new packager.PackagerDto) []Change {
+	var changes []Change
+
+	if oldPrefix, newPrefix := prefixString(old.Prefix), prefixString(new.Prefix); oldPrefix != newPrefix {
+		changes = append(changes, Change{FieldId: PrefixFieldId, Attribute: "length", Old: oldPrefix, New: newPrefix})
+	}
+
+	oldFields, newFields := fieldsById(old), fieldsById(new)
+
+	fieldIds := make([]int, 0, len(oldFields)+len(newFields))
+	for fieldId := range oldFields {
+		fieldIds = append(fieldIds, fieldId)
+	}
+	for fieldId := range newFields {
+		if _, ok := oldFields[fieldId]; !ok {
+			fieldIds = append(fieldIds, fieldId)
+		}
+	}
+	sort.Ints(fieldIds)
+
+	for _, fieldId := range fieldIds {
+		oldField, inOld := oldFields[fieldId]
+		newField, inNew := newFields[fieldId]
+
+		switch {
+		case !inOld:
+			changes = append(changes, Change{FieldId: fieldId, New: fieldString(newField)})
+		case !inNew:
+			changes = append(changes, Change{FieldId: fieldId, Old: fieldString(oldField)})
+		default:
+			changes = append(changes, fieldChanges(fieldId, oldField, newField)...)
+		}
+	}
+
+	return changes
+}
+
+func fieldChanges(fieldId int, old, new packager.FieldDto) []Change {
+	attributes := []struct {
+		name     string
+		old, new string
+	}{
+		{"description", old.Description, new.Description},
+		{"type", old.Type.String(), new.Type.String()},
+		{"length", strconv.Itoa(old.Length), strconv.Itoa(new.Length)},
+		{"pattern", old.Pattern, new.Pattern},
+		{"encoding", old.Encoding.String(), new.Encoding.String()},
+		{"prefix", prefixString(old.Prefix), prefixString(new.Prefix)},
+		{"padding", paddingString(old.Padding), paddingString(new.Padding)},
+	}
+
+	var changes []Change
+	for _, attribute := range attributes {
+		if attribute.old != attribute.new {
+			changes = append(changes, Change{FieldId: fieldId, Attribute: attribute.name, Old: attribute.old, New: attribute.new})
+		}
+	}
+
+	return changes
+}
+
+// fieldsById returns the fields of dto by id, the keys that are not numbers are ignored
+// like the loader of the packager rejects them
+func fieldsById(dto packager.PackagerDto) map[int]packager.FieldDto {
+	fields := make(map[int]packager.FieldDto, len(dto.Fields))
+	for key, fld := range dto.Fields {
+		fieldId, err := strconv.Atoi(key)
+		if err == nil {
+			fields[fieldId] = fld
+		}
+	}
+
+	return fields
+}
+
+func fieldString(f packager.FieldDto) string {
+	return fmt.Sprintf("%s %s length %d prefix %s padding %s pattern %s",
+		f.Type.String(), f.Encoding.String(), f.Length, prefixString(f.Prefix), paddingString(f.Padding), f.Pattern)
+}
+
+func prefixString(p prefix.Prefix) string {
+	if p.Type == prefix.Fixed {
+		return "FIXED"
+	}
+
+	value := fmt.Sprintf("%s %s", p.Type.String(), p.Encoding.String())
+	if p.Hex {
+		value += " hex"
+	}
+	if p.IsInclusive {
+		value += " inclusive"
+	}
+
+	return value
+}
+
+func paddingString(p padding.Padding) string {
+	if p.Type == padding.None {
+		return "NONE"
+	}
+
+	return fmt.Sprintf("%s %s %q", p.Type.String(), p.Position.String(), p.Char)
+}
@@ -0,0 +1,9 @@
+package registry
+
+import "errors"
+
+var (
+	ErrNotFound        = errors.New("packager not found in registry")
+	ErrInvalidPackager = errors.New("invalid packager")
+	ErrReadDir         = errors.New("failed to read packager directory")
+)
@@ -0,0 +1,388 @@
+// Package registry loads the packagers of a directory by name and reloads them when their files change.
+//
+// Every version is validated before it replaces the previous one, and the swap is atomic: a server
+// connection keeps the *packager.Packager it was accepted with and a message the one it was created
+// with, so the connections and requests in flight finish with the version they started with while
+// the next ones use the new version. The registry only keeps the current version: a replaced one,
+// and the subfields registered for it, are released once its connections and messages are gone.
+//
+//	packagers, err := registry.New("./iso8583/packager", registry.WithLogger(log))
+//	pkg, err := packagers.Get("iso87BPackager")
+//	current, err := packagers.Func("iso87BPackager")
+//	srv := server.New(8015, pkg, handler, server.WithPackagerFunc(current))
+//	stop := packagers.Watch()
+//	defer stop()
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/packager"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Registry holds the current version of every packager of a directory, by the name
+// of its file without the .json extension
+type Registry struct {
+	dir      string
+	interval time.Duration
+	logger   *logger.Logger
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+
+	reloadMu sync.Mutex
+	files    map[string]fileState
+}
+
+type entry struct {
+	current atomic.Pointer[packager.Packager]
+	version int
+	file    string
+	dto     packager.PackagerDto
+	// files are the packager file and the subfield files it references
+	files []string
+}
+
+// fileState is what is compared to detect that a file changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+type Option func(*Registry)
+
+// WithInterval sets how often Watch checks the files of the directory, every two seconds by default
+func WithInterval(interval time.Duration) Option {
+	return func(r *Registry) {
+		r.interval = interval
+	}
+}
+
+// WithLogger logs the reloads with the changed fields and the versions that are rejected
+func WithLogger(l *logger.Logger) Option {
+	return func(r *Registry) {
+		r.logger = l
+	}
+}
+
+// New loads every packager of dir, the JSON files with fields. The subfield and header files
+// of the directory are not packagers. It fails when a packager is not valid.
+func New(dir string, opts ...Option) (*Registry, error) {
+	r := Registry{
+		dir:      dir,
+		interval: 2 * time.Second,
+		entries:  make(map[string]*entry),
+		files:    make(map[string]fileState),
+	}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Get returns the current version of the packager name
+func (r *Registry) Get(name string) (*packager.Packager, error) {
+	e, err := r.entry(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.current.Load(), nil
+}
+
+// Func returns a function that returns the current version of the packager name,
+// for server.WithPackagerFunc and client.WithPackagerFunc
+func (r *Registry) Func(name string) (func() *packager.Packager, error) {
+	e, err := r.entry(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.current.Load, nil
+}
+
+// Names returns the names of the packagers, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (r *Registry) entry(name string) (*entry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	return e, nil
+}
+
+// Watch checks the files of the directory every interval and reloads the packagers whose file,
+// or a subfield file they reference, changed. It returns a function that stops watching.
+func (r *Registry) Watch() (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = r.Reload()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// Reload loads again the packagers whose files changed since the last check and the packagers
+// added to the directory. A version that fails to load or validate is logged and returned in
+// the error, and the previous version stays in use.
+func (r *Registry) Reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	dirEntries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrReadDir, err)
+	}
+
+	files := make(map[string]fileState)
+	changed := make(map[string]bool)
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.EqualFold(filepath.Ext(dirEntry.Name()), ".json") {
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		state := fileState{modTime: info.ModTime(), size: info.Size()}
+		files[dirEntry.Name()] = state
+
+		if previous, ok := r.files[dirEntry.Name()]; !ok || previous != state {
+			changed[dirEntry.Name()] = true
+		}
+	}
+
+	for file := range r.files {
+		if _, ok := files[file]; !ok {
+			changed[file] = true
+		}
+	}
+
+	r.files = files
+
+	var errs []error
+	for _, file := range r.packagerFiles(files, changed) {
+		err = r.load(file)
+		if err != nil {
+			r.logError(err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// packagerFiles returns the files to load: the changed files and the packagers that reference
+// a changed file. Removed packagers keep their last version.
+func (r *Registry) packagerFiles(files map[string]fileState, changed map[string]bool) []string {
+	load := make(map[string]bool)
+	for file := range changed {
+		if _, ok := files[file]; ok {
+			load[file] = true
+		}
+	}
+
+	r.mu.RLock()
+	for name, e := range r.entries {
+		if _, ok := files[e.file]; !ok {
+			if changed[e.file] {
+				r.logWarn(fmt.Sprintf("packager %s: %s was removed, version %d stays in use", name, e.file, e.version))
+			}
+			continue
+		}
+
+		for _, file := range e.files {
+			if changed[file] {
+				load[e.file] = true
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	result := make([]string, 0, len(load))
+	for file := range load {
+		result = append(result, file)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// definition is the content of a packager file: the definition of the fields and the subfield
+// files they reference
+type definition struct {
+	packager.PackagerDto
+	Fields map[string]struct {
+		packager.FieldDto
+		SubFieldsFile *string `json:"subFieldsFile"`
+	} `json:"fields"`
+}
+
+// load loads file when it is a packager and swaps it in after it validates
+func (r *Registry) load(file string) error {
+	name := strings.TrimSuffix(file, filepath.Ext(file))
+
+	byteValue, err := os.ReadFile(filepath.Join(r.dir, file))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidPackager, name, err)
+	}
+
+	// the header and subfield files of the directory have no fields, they are not packagers
+	var kind struct {
+		Fields json.RawMessage `json:"fields"`
+	}
+	err = json.Unmarshal(byteValue, &kind)
+	if err == nil && kind.Fields == nil {
+		return nil
+	}
+
+	var def definition
+	if err == nil {
+		err = json.Unmarshal(byteValue, &def)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidPackager, name, err)
+	}
+
+	dto := def.PackagerDto
+	dto.Fields = make(map[string]packager.FieldDto, len(def.Fields))
+	files := []string{file}
+	for key, fld := range def.Fields {
+		dto.Fields[key] = fld.FieldDto
+		if fld.SubFieldsFile != nil && *fld.SubFieldsFile != "" {
+			files = append(files, *fld.SubFieldsFile)
+		}
+	}
+
+	pkg, err := subfield.LoadFromJson(r.dir, file)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidPackager, name, err)
+	}
+
+	err = validate(pkg)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidPackager, name, err)
+	}
+
+	r.mu.Lock()
+	e, ok := r.entries[name]
+	if !ok {
+		e = &entry{file: file}
+		r.entries[name] = e
+	}
+	previous := e.dto
+	e.version++
+	e.dto = dto
+	e.files = files
+	e.current.Store(pkg)
+	version := e.version
+	r.mu.Unlock()
+
+	if !ok {
+		r.logInfo(fmt.Sprintf("packager %s loaded from %s", name, file))
+		return nil
+	}
+
+	changes := Diff(previous, dto)
+	if len(changes) == 0 {
+		r.logInfo(fmt.Sprintf("packager %s reloaded to version %d without field changes", name, version))
+		return nil
+	}
+
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = change.String()
+	}
+	r.logInfo(fmt.Sprintf("packager %s reloaded to version %d: %s", name, version, strings.Join(lines, "; ")))
+
+	return nil
+}
+
+// validate checks that pkg can frame and pack messages: a length prefix, the MTI and the bitmap
+func validate(pkg *packager.Packager) error {
+	if pkg.Prefix == nil {
+		return errors.New("length prefix not defined")
+	}
+
+	for _, fieldId := range []int{0, 1} {
+		if _, ok := pkg.Fields[fieldId]; !ok {
+			return fmt.Errorf("field %d not defined", fieldId)
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) logInfo(i interface{}) {
+	if r.logger != nil {
+		r.logger.Info(nil, logger.Message, i)
+	}
+}
+
+func (r *Registry) logWarn(i interface{}) {
+	if r.logger != nil {
+		r.logger.Warn(nil, i)
+	}
+}
+
+func (r *Registry) logError(err error) {
+	if r.logger != nil {
+		r.logger.Error(nil, err)
+	}
+}
@@ -0,0 +1,259 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/packager"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// copyPackagers copies the packagers of the repository to a directory the test can change
+func copyPackagers(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	files, err := filepath.Glob("../iso8583/packager/*.json")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+
+	for _, file := range files {
+		byteValue, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+
+		err = os.WriteFile(filepath.Join(dir, filepath.Base(file)), byteValue, 0644)
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	return dir
+}
+
+// change replaces old with new in file, and moves its modification time forward so
+// the change is detected even within the resolution of the file system
+func change(t *testing.T, dir, file, old, new string) {
+	t.Helper()
+
+	path := filepath.Join(dir, file)
+
+	byteValue, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if old != "" && !bytes.Contains(byteValue, []byte(old)) {
+		t.Fatalf("%s does not contain %q", file, old)
+	}
+
+	err = os.WriteFile(path, bytes.Replace(byteValue, []byte(old), []byte(new), 1), 0644)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	err = os.Chtimes(path, later, later)
+	if err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+const terminalId = "\"Card Acceptor Terminal Identification\",\n\t\t\t\"type\": \"NUMERIC\",\n\t\t\t\"length\": 8"
+
+func TestNew(t *testing.T) {
+	dir := copyPackagers(t)
+
+	r, err := New(dir)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	// the header and subfield files are not packagers
+	want := []string{"iso87BPackager", "iso87BVisaBase1Packager", "iso87EAmexPackager"}
+	if names := r.Names(); strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("names %q, want %q", names, want)
+	}
+
+	_, err = r.Get("iso93Packager")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("get returned %v, want %v", err, ErrNotFound)
+	}
+
+	_, err = New(filepath.Join(dir, "missing"))
+	if !errors.Is(err, ErrReadDir) {
+		t.Errorf("new returned %v, want %v", err, ErrReadDir)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := copyPackagers(t)
+
+	var out bytes.Buffer
+	r, err := New(dir, WithLogger(logger.New(logger.Info, "registry", logger.WithWriter(&out))))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	previous, _ := r.Get("iso87BPackager")
+	current, err := r.Func("iso87BPackager")
+	if err != nil {
+		t.Fatalf("func: %v", err)
+	}
+
+	// without changes nothing is loaded again
+	err = r.Reload()
+	if err != nil || current() != previous {
+		t.Fatalf("reload without changes returned %v, replaced %v", err, current() != previous)
+	}
+
+	change(t, dir, "iso87BPackager.json", terminalId, strings.Replace(terminalId, "8", "10", 1))
+	out.Reset()
+
+	err = r.Reload()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	if current() == previous || current().Fields[41].Length() != 10 {
+		t.Errorf("the new version is not in use")
+	}
+
+	// the version in use by a connection is not modified
+	if previous.Fields[41].Length() != 8 {
+		t.Errorf("the previous version was modified")
+	}
+
+	if !strings.Contains(out.String(), "version 2: field 41 length: 8 -> 10") {
+		t.Errorf("the reload is not logged with its changes: %s", out.String())
+	}
+
+	// a version that is not valid is rejected and the previous one stays in use
+	reloaded := current()
+	for _, tt := range []struct{ old, new string }{
+		{"\"fields\": {", "\"fields\": {,"},
+		{"\"000\": {", "\"999\": {"},
+	} {
+		change(t, dir, "iso87BPackager.json", tt.old, tt.new)
+
+		err = r.Reload()
+		if !errors.Is(err, ErrInvalidPackager) {
+			t.Errorf("reload of %s returned %v, want %v", tt.new, err, ErrInvalidPackager)
+		}
+
+		if current() != reloaded {
+			t.Errorf("the invalid version of %s is in use", tt.new)
+		}
+
+		change(t, dir, "iso87BPackager.json", tt.new, tt.old)
+	}
+}
+
+func TestReloadSubfields(t *testing.T) {
+	dir := copyPackagers(t)
+
+	r, err := New(dir)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	visa, _ := r.Get("iso87BVisaBase1Packager")
+	other, _ := r.Get("iso87BPackager")
+
+	// a change of a subfield file reloads the packagers that reference it
+	change(t, dir, "subFieldsVisaDe126.json", "", "")
+
+	err = r.Reload()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	if current, _ := r.Get("iso87BVisaBase1Packager"); current == visa {
+		t.Errorf("the packager of the subfield file was not reloaded")
+	}
+
+	if current, _ := r.Get("iso87BPackager"); current != other {
+		t.Errorf("a packager without the subfield file was reloaded")
+	}
+
+	// the replaced version keeps its subfields while it is in use
+	if _, ok := subfield.Lookup(visa, 126); !ok {
+		t.Errorf("the replaced version lost its subfields")
+	}
+
+	// a removed packager keeps its last version
+	err = os.Remove(filepath.Join(dir, "iso87BPackager.json"))
+	if err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	err = r.Reload()
+	if current, _ := r.Get("iso87BPackager"); err != nil || current != other {
+		t.Errorf("reload after removing the packager returned %v", err)
+	}
+}
+
+func loadDto(t *testing.T, file string) packager.PackagerDto {
+	t.Helper()
+
+	byteValue, err := os.ReadFile(filepath.Join("../iso8583/packager", file))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	var dto packager.PackagerDto
+	err = json.Unmarshal(byteValue, &dto)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	return dto
+}
+
+func TestDiff(t *testing.T) {
+	old := loadDto(t, "iso87BPackager.json")
+
+	if changes := Diff(old, loadDto(t, "iso87BPackager.json")); len(changes) != 0 {
+		t.Errorf("changes of the same packager %v", changes)
+	}
+
+	changed := loadDto(t, "iso87BPackager.json")
+	delete(changed.Fields, "002")
+	changed.Fields["128"] = old.Fields["064"]
+
+	field := changed.Fields["041"]
+	field.Length = 10
+	field.Pattern = "^[0-9\\s]{10}$"
+	changed.Fields["041"] = field
+
+	changes := Diff(old, changed)
+	if len(changes) != 4 {
+		t.Fatalf("changes %v, want 4", changes)
+	}
+
+	if changes[0].FieldId != 2 || changes[0].New != "" || !strings.HasPrefix(changes[0].String(), "field 2 removed: ") {
+		t.Errorf("change %s, want field 2 removed", changes[0])
+	}
+
+	if changes[1].String() != "field 41 length: 8 -> 10" || changes[2].Attribute != "pattern" {
+		t.Errorf("changes %s and %s, want length and pattern of field 41", changes[1], changes[2])
+	}
+
+	if changes[3].FieldId != 128 || !strings.HasPrefix(changes[3].String(), "field 128 added: ") {
+		t.Errorf("change %s, want field 128 added", changes[3])
+	}
+
+	changed.Prefix.IsInclusive = true
+	if changes = Diff(old, changed); len(changes) != 5 || changes[0].String() != "prefix length: LLLL BINARY -> LLLL BINARY inclusive" {
+		t.Errorf("the change of the prefix is not first: %v", changes)
+	}
+}
@@ -0,0 +1,140 @@
+// Package replay re-sends captured traffic to a server and compares
+// the responses with the recorded ones.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/journal"
+	"github.com/tomasdemarco/go-pos/mti"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Record is a captured request and the response it received
+type Record struct {
+	Time     time.Time
+	Request  map[int]string
+	Response map[int]string
+	// Masked reports that the fields are the values logged by a journal, with its masking policy applied
+	Masked bool
+}
+
+// captureDto is a line of a capture file
+//
+//	{"time":"2025-02-27T15:24:17Z","request":{"0":"0200",...},"response":{"0":"0210",...}}
+type captureDto struct {
+	Time      time.Time          `json:"time"`
+	Request   map[string]string  `json:"request"`
+	Response  map[string]string  `json:"response"`
+	Direction *journal.Direction `json:"direction"`
+}
+
+// ReadCapture reads the records of a JSON lines capture. Each line is either a capture record
+// with request and response fields, or a journal entry. A journal request is paired with the next
+// response of the same id in the other direction: received and sent on the side of a server, sent
+// and received on the side of a client. The entries that can not be paired are skipped.
+//
+// Journal entries are read from their logged fields, which the masking policy of the journal
+// masks or leaves out (PAN, track data, PIN block). Those fields have to be supplied to the
+// replayer, e.g. with the values of a test card in Replayer.Fields.
+func ReadCapture(r io.Reader) ([]Record, error) {
+	records := make([]Record, 0)
+	pending := make(map[string]journal.Entry)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var dto captureDto
+		err := json.Unmarshal(scanner.Bytes(), &dto)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidCapture, line, err)
+		}
+
+		if dto.Direction == nil {
+			request, err := parseFields(dto.Request)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidCapture, line, err)
+			}
+
+			response, err := parseFields(dto.Response)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidCapture, line, err)
+			}
+
+			records = append(records, Record{Time: dto.Time, Request: request, Response: response})
+			continue
+		}
+
+		var entry journal.Entry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidCapture, line, err)
+		}
+
+		if entry.Id == "" || entry.Mti == "" {
+			continue
+		}
+
+		if !mti.IsResponse(entry.Mti) {
+			pending[entry.Id] = entry
+			continue
+		}
+
+		request, ok := pending[entry.Id]
+		if !ok || request.Direction == entry.Direction {
+			continue
+		}
+		delete(pending, entry.Id)
+
+		requestFields, err := parseFields(request.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidCapture, line, err)
+		}
+
+		responseFields, err := parseFields(entry.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidCapture, line, err)
+		}
+
+		records = append(records, Record{Time: request.Time, Request: requestFields, Response: responseFields, Masked: true})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCapture, err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	return records, nil
+}
+
+func parseFields(fields map[string]string) (map[int]string, error) {
+	if fields == nil {
+		return nil, nil
+	}
+
+	parsed := make(map[int]string, len(fields))
+	for k, v := range fields {
+		fieldId, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFieldNumber, k)
+		}
+
+		if fieldId != 1 {
+			parsed[fieldId] = v
+		}
+	}
+
+	return parsed, nil
+}
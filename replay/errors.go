@@ -0,0 +1,8 @@
+package replay
+
+import "errors"
+
+var (
+	ErrInvalidCapture     = errors.New("invalid capture")
+	ErrInvalidFieldNumber = errors.New("invalid field number")
+)
@@ -0,0 +1,234 @@
+package replay
+
+import (
+	"fmt"
+	"github.com/tomasdemarco/go-pos/client"
+	"github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/mask"
+	"github.com/tomasdemarco/iso8583/message"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VolatileFields are rewritten on every replayed request and are not compared:
+// transmission date and time (DE7), STAN (DE11) and local time and date (DE12, DE13)
+var VolatileFields = []int{7, 11, 12, 13}
+
+// Replayer sends the captured requests through a client
+type Replayer struct {
+	Client *client.Client
+	// Speed scales the original pacing: 1 keeps it, 2 replays twice as fast,
+	// 0 sends every request as soon as the previous response is received
+	Speed float64
+	// IgnoreFields are not compared in addition to VolatileFields, e.g. the authorization code (DE38)
+	IgnoreFields []int
+	// Masking is the policy of the journal the records were read from. The responses are compared
+	// with the masked records after it is applied, and the values of the diffs are masked with it.
+	Masking *mask.Policy
+	// Fields are set in every request, e.g. the PAN (DE2) and track 2 (DE35) of a test card
+	// for the fields that the masking policy of a journal masks or leaves out
+	Fields map[int]string
+	// Rewrite is called for every request after the volatile fields are rewritten
+	Rewrite func(msg *message.Message)
+}
+
+// Result is the outcome of a replayed record
+type Result struct {
+	Index    int
+	Request  *message.Message
+	Response *message.Message
+	Diffs    []Diff
+	Elapsed  time.Duration
+	Err      error
+}
+
+// Diff is a field whose value in the response differs from the recorded one,
+// with the values masked by the policy of the replayer
+type Diff struct {
+	Field    int
+	Expected string
+	Actual   string
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("field %d: expected %q, got %q", d.Field, d.Expected, d.Actual)
+}
+
+// Summary counts the results of a replay
+type Summary struct {
+	Total      int
+	Matched    int
+	Mismatched int
+	Failed     int
+}
+
+func NewReplayer(c *client.Client) *Replayer {
+	return &Replayer{
+		Client:  c,
+		Speed:   1,
+		Masking: mask.DefaultPolicy(),
+	}
+}
+
+// Run replays the records and calls fn with the result of each one, in the order of the records
+func (r *Replayer) Run(records []Record, fn func(Result)) Summary {
+	results := make([]Result, len(records))
+
+	if r.Speed <= 0 {
+		for i, record := range records {
+			results[i] = r.replay(i, record)
+		}
+	} else {
+		wg := sync.WaitGroup{}
+		start := time.Now()
+
+		for i, record := range records {
+			offset := time.Duration(float64(record.Time.Sub(records[0].Time)) / r.Speed)
+			time.Sleep(offset - time.Since(start))
+
+			wg.Add(1)
+			go func(i int, record Record) {
+				defer wg.Done()
+				results[i] = r.replay(i, record)
+			}(i, record)
+		}
+
+		wg.Wait()
+	}
+
+	summary := Summary{Total: len(records)}
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			summary.Failed++
+		case len(result.Diffs) > 0:
+			summary.Mismatched++
+		default:
+			summary.Matched++
+		}
+
+		if fn != nil {
+			fn(result)
+		}
+	}
+
+	return summary
+}
+
+func (r *Replayer) replay(index int, record Record) Result {
+	result := Result{Index: index}
+
+	msg := message.NewMessage(r.Client.Packager)
+	for fieldId, value := range record.Request {
+		msg.SetField(fieldId, value)
+	}
+	for fieldId, value := range r.Fields {
+		msg.SetField(fieldId, value)
+	}
+
+	r.rewrite(msg)
+	if r.Rewrite != nil {
+		r.Rewrite(msg)
+	}
+
+	result.Request = msg
+
+	start := time.Now()
+	response, err := r.Client.Do(context.NewRequestContext(nil, msg), msg)
+	result.Elapsed = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Response = response
+	if record.Response != nil {
+		result.Diffs = r.compare(record, response)
+	}
+
+	return result
+}
+
+// rewrite sets new values to the volatile fields present in msg
+func (r *Replayer) rewrite(msg *message.Message) {
+	now := time.Now()
+
+	if _, err := msg.GetField(7); err == nil {
+		msg.SetField(7, now.UTC().Format("0102150405"))
+	}
+
+	if _, err := msg.GetField(11); err == nil {
+		msg.SetField(11, fmt.Sprintf("%06d", r.Client.Stan.Next()))
+	}
+
+	if _, err := msg.GetField(12); err == nil {
+		msg.SetField(12, now.Format("150405"))
+	}
+
+	if _, err := msg.GetField(13); err == nil {
+		msg.SetField(13, now.Format("0102"))
+	}
+}
+
+// compare returns the fields of the response that differ from the expected ones. The fields of
+// a masked record are compared with the masked response, and the fields the policy leaves out
+// of the journal are not compared.
+func (r *Replayer) compare(record Record, response *message.Message) []Diff {
+	ignore := make(map[int]bool)
+	for _, fieldId := range append(append([]int{1}, VolatileFields...), r.IgnoreFields...) {
+		ignore[fieldId] = true
+	}
+
+	expected := record.Response
+
+	fieldIds := make(map[int]bool)
+	for fieldId := range expected {
+		fieldIds[fieldId] = true
+	}
+	for fieldId := range response.Fields {
+		fieldIds[fieldId] = true
+	}
+
+	diffs := make([]Diff, 0)
+	for fieldId := range fieldIds {
+		if ignore[fieldId] {
+			continue
+		}
+
+		actual, logged := r.mask(fieldId, response.Fields[fieldId])
+		if record.Masked && !logged {
+			continue
+		}
+
+		if record.Masked && equal(expected[fieldId], actual) ||
+			!record.Masked && equal(expected[fieldId], response.Fields[fieldId]) {
+			continue
+		}
+
+		// the expected value of a journal is masked again, in case the policy of the replayer masks more
+		expectedValue, _ := r.mask(fieldId, expected[fieldId])
+
+		diffs = append(diffs, Diff{Field: fieldId, Expected: expectedValue, Actual: actual})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Field < diffs[j].Field
+	})
+
+	return diffs
+}
+
+// mask returns value masked by the policy of the replayer and false if the policy leaves it out
+func (r *Replayer) mask(fieldId int, value string) (string, bool) {
+	if r.Masking == nil || value == "" {
+		return value, true
+	}
+
+	return r.Masking.Value(fieldId, value)
+}
+
+func equal(expected, actual string) bool {
+	return strings.TrimSpace(expected) == strings.TrimSpace(actual)
+}
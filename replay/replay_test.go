@@ -0,0 +1,195 @@
+package replay
+
+import (
+	"errors"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/mask"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/packager"
+	"strings"
+	"testing"
+)
+
+const (
+	pan = "4541234567890123"
+	// 9F02 amount and 5A PAN, the policy removes the PAN tag
+	emv       = "9F0206000000001000" + "5A084541234567890123"
+	maskedPan = "454123******0123"
+	maskedEmv = "9F0206000000001000"
+)
+
+func loadPackager(t *testing.T) *packager.Packager {
+	t.Helper()
+
+	pkg, err := subfield.LoadFromJson("../iso8583/packager", "iso87BPackager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+func TestReadCapture(t *testing.T) {
+	capture := strings.Join([]string{
+		// a server journal: two requests received and their responses sent out of order
+		`{"time":"2025-02-27T15:24:17Z","direction":"received","id":"a","mti":"0200","fields":{"0":"0200","11":"000001"}}`,
+		`{"time":"2025-02-27T15:24:18Z","direction":"received","id":"b","mti":"0200","fields":{"0":"0200","11":"000002"}}`,
+		`{"time":"2025-02-27T15:24:19Z","direction":"sent","id":"b","mti":"0210","fields":{"0":"0210","11":"000002","39":"05"}}`,
+		`{"time":"2025-02-27T15:24:19Z","direction":"sent","id":"a","mti":"0210","fields":{"0":"0210","11":"000001","39":"00"}}`,
+		// a response in the direction of its request is not paired
+		`{"time":"2025-02-27T15:24:20Z","direction":"sent","id":"c","mti":"0200","fields":{"0":"0200","11":"000003"}}`,
+		`{"time":"2025-02-27T15:24:21Z","direction":"sent","id":"c","mti":"0210","fields":{"0":"0210","11":"000003"}}`,
+		// a response without request and an entry without id are skipped
+		`{"time":"2025-02-27T15:24:22Z","direction":"received","id":"d","mti":"0210","fields":{"0":"0210"}}`,
+		`{"time":"2025-02-27T15:24:22Z","direction":"received","mti":"0200","fields":{"0":"0200"}}`,
+		``,
+		// a capture record
+		`{"time":"2025-02-27T15:24:16Z","request":{"0":"0800","1":"8220000000000000","11":"000000"},"response":{"0":"0810","39":"00"}}`,
+	}, "\n")
+
+	records, err := ReadCapture(strings.NewReader(capture))
+	if err != nil {
+		t.Fatalf("read capture: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("read %d records, want 3", len(records))
+	}
+
+	// the records are in the order of their requests
+	want := []struct {
+		stan, responseCode string
+		masked             bool
+	}{
+		{"000000", "00", false},
+		{"000001", "00", true},
+		{"000002", "05", true},
+	}
+
+	for i, w := range want {
+		record := records[i]
+		if record.Request[11] != w.stan || record.Response[39] != w.responseCode || record.Masked != w.masked {
+			t.Errorf("record %d is %v -> %v, masked %v", i, record.Request, record.Response, record.Masked)
+		}
+	}
+
+	if _, ok := records[0].Request[1]; ok {
+		t.Errorf("the bitmap of the capture is in the request")
+	}
+
+	for _, line := range []string{`{"request":`, `{"request":{"DE2":"1"}}`} {
+		_, err = ReadCapture(strings.NewReader(line))
+		if !errors.Is(err, ErrInvalidCapture) {
+			t.Errorf("read capture %s returned %v, want %v", line, err, ErrInvalidCapture)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	pkg := loadPackager(t)
+
+	// the policy of the journal also leaves out the additional data
+	policy := mask.DefaultPolicy()
+	policy.Fields[48] = mask.Remove
+
+	r := &Replayer{IgnoreFields: []int{38}, Masking: policy}
+
+	response := gopostest.NewMessage(pkg, map[int]string{
+		0: "0210", 2: pan, 7: "0227152417", 11: "000009", 35: pan + "D2612101", 38: "123456", 39: "00", 48: "secret", 52: "0123456789ABCDEF", 55: emv,
+	})
+
+	// the journal has the masked values, the volatile and ignored fields of another call and no DE48
+	journal := Record{Masked: true, Response: map[int]string{
+		0: "0210", 2: maskedPan, 7: "0101120000", 11: "000001", 35: maskedPan + "D*******", 38: "654321", 39: "00", 52: "****************", 55: maskedEmv,
+	}}
+
+	if diffs := r.compare(journal, response); len(diffs) != 0 {
+		t.Errorf("diffs of the masked record %v", diffs)
+	}
+
+	// a capture has the clear values
+	capture := Record{Response: map[int]string{
+		0: "0210", 2: pan, 35: pan + "D2612101", 39: "00", 48: "secret", 52: "0123456789ABCDEF", 55: emv,
+	}}
+
+	if diffs := r.compare(capture, response); len(diffs) != 0 {
+		t.Errorf("diffs of the capture %v", diffs)
+	}
+
+	// the diffs are masked on both sides
+	capture.Response[2] = "4541239999990123"
+	capture.Response[39] = "05"
+	journal.Response[39] = "05"
+	journal.Response[55] = emv
+
+	for _, record := range []Record{capture, journal} {
+		diffs := r.compare(record, response)
+		for _, diff := range diffs {
+			if strings.Contains(diff.String(), pan) || strings.Contains(diff.String(), "999999") {
+				t.Errorf("diff %s is not masked", diff)
+			}
+		}
+
+		if record.Masked && (len(diffs) != 2 || diffs[0].Field != 39 || diffs[1].Field != 55) {
+			t.Errorf("diffs of the masked record %v, want DE39 and DE55", diffs)
+		}
+
+		if !record.Masked && (len(diffs) != 2 || diffs[0].Field != 2 || diffs[1].String() != `field 39: expected "05", got "00"`) {
+			t.Errorf("diffs of the capture %v", diffs)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	pkg := loadPackager(t)
+
+	// the host echoes the card data in the response
+	h := gopostest.New(t, pkg, func(c *ctx.RequestContext, srv *server.Server) {
+		response, err := srv.NewResponse(c.Request)
+		if err != nil {
+			return
+		}
+
+		response.SetField(2, c.Request.Fields[2])
+		response.SetField(55, c.Request.Fields[55])
+		response.SetField(39, "00")
+		_ = srv.SendResponse(c, response)
+	})
+
+	records := []Record{
+		{
+			Masked:   true,
+			Request:  map[int]string{0: "0200", 2: maskedPan, 3: "000000", 4: "000000001000", 11: "000001", 55: maskedEmv},
+			Response: map[int]string{0: "0210", 2: maskedPan, 3: "000000", 4: "000000001000", 11: "000001", 39: "00", 55: maskedEmv},
+		},
+		{
+			Masked:   true,
+			Request:  map[int]string{0: "0200", 2: maskedPan, 3: "000000", 4: "000000002000", 11: "000002", 55: maskedEmv},
+			Response: map[int]string{0: "0210", 2: maskedPan, 3: "000000", 4: "000000002000", 11: "000002", 39: "51", 55: maskedEmv},
+		},
+	}
+
+	r := NewReplayer(h.Client)
+	r.Speed = 0
+	// the test card replaces the PAN and the EMV data the journal masks
+	r.Fields = map[int]string{2: pan, 55: emv}
+
+	var results []Result
+	summary := r.Run(records, func(result Result) {
+		results = append(results, result)
+	})
+
+	if summary != (Summary{Total: 2, Matched: 1, Mismatched: 1}) {
+		t.Fatalf("summary %+v, results %+v", summary, results)
+	}
+
+	if results[0].Request.Fields[2] != pan {
+		t.Errorf("request %v, want the PAN of the test card", results[0].Request.Fields)
+	}
+
+	if diffs := results[1].Diffs; len(diffs) != 1 || diffs[0].Field != 39 {
+		t.Errorf("diffs %v, want the response code", diffs)
+	}
+}
@@ -0,0 +1,9 @@
+package sequence
+
+import "errors"
+
+var (
+	ErrInvalidRange = errors.New("invalid sequence range")
+	ErrReadFile     = errors.New("failed to read sequence file")
+	ErrWriteFile    = errors.New("failed to write sequence file")
+)
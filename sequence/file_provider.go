@@ -0,0 +1,230 @@
+package sequence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const dateFormat = "20060102"
+
+// FileProvider keeps the sequences in a JSON file. The file is replaced atomically (written to a
+// temporary file, synced and renamed), so a crash leaves either the previous or the new content.
+//
+// To avoid a write for every number, a block of numbers can be reserved with WithReserve: the file
+// records the end of the block, and after a restart the sequence continues from there, skipping
+// the numbers of the block that were not used but never repeating one.
+type FileProvider struct {
+	mu        sync.Mutex
+	path      string
+	min       int
+	max       int
+	reserve   int
+	location  *time.Location
+	now       func() time.Time
+	errorFunc func(error)
+
+	states map[string]*state
+}
+
+// state of a sequence, Next is the number that is returned after a restart
+type state struct {
+	Next      int    `json:"next"`
+	Date      string `json:"date,omitempty"`
+	current   int
+	remaining int
+}
+
+type FileOption func(*FileProvider)
+
+// WithRange sets the numbers of the sequences, after max they start again from min
+func WithRange(min, max int) FileOption {
+	return func(p *FileProvider) {
+		p.min = min
+		p.max = max
+	}
+}
+
+// WithReserve writes the file once every n numbers instead of for every number
+func WithReserve(n int) FileOption {
+	return func(p *FileProvider) {
+		p.reserve = n
+	}
+}
+
+// WithDailyReset starts the sequences again from the minimum every day at midnight in location
+func WithDailyReset(location *time.Location) FileOption {
+	return func(p *FileProvider) {
+		p.location = location
+	}
+}
+
+// WithClock sets the function that returns the current time, used for the daily reset
+func WithClock(now func() time.Time) FileOption {
+	return func(p *FileProvider) {
+		p.now = now
+	}
+}
+
+// WithErrorFunc is called when the file cannot be written. The sequence keeps going in memory
+// and the write is retried on the next number.
+func WithErrorFunc(f func(error)) FileOption {
+	return func(p *FileProvider) {
+		p.errorFunc = f
+	}
+}
+
+// NewFileProvider opens the sequences of the file in path, which is created on the first number
+func NewFileProvider(path string, opts ...FileOption) (*FileProvider, error) {
+	p := FileProvider{
+		path:    path,
+		min:     1,
+		max:     999999,
+		reserve: 1,
+		now:     time.Now,
+		states:  make(map[string]*state),
+	}
+
+	for _, opt := range opts {
+		opt(&p)
+	}
+
+	if p.min < 0 || p.min > p.max {
+		return nil, fmt.Errorf("%w: %d-%d", ErrInvalidRange, p.min, p.max)
+	}
+
+	if p.reserve < 1 {
+		p.reserve = 1
+	}
+
+	byteValue, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", ErrReadFile, err)
+	}
+
+	if len(byteValue) > 0 {
+		err = json.Unmarshal(byteValue, &p.states)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadFile, err)
+		}
+	}
+
+	for _, s := range p.states {
+		s.current = s.Next
+		if s.current < p.min || s.current > p.max {
+			s.current = p.min
+		}
+	}
+
+	return &p, nil
+}
+
+// Sequence returns the sequence of key
+func (p *FileProvider) Sequence(key string) Sequence {
+	return &fileSequence{provider: p, key: key}
+}
+
+// Next returns the next number of the sequence of key
+func (p *FileProvider) Next(key string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.states[key]
+	if !ok {
+		s = &state{current: p.min}
+		p.states[key] = s
+	}
+
+	if p.location != nil {
+		today := p.now().In(p.location).Format(dateFormat)
+		if s.Date != today {
+			s.Date = today
+			s.current = p.min
+			s.remaining = 0
+		}
+	}
+
+	if s.remaining == 0 {
+		s.Next = p.advance(s.current, p.reserve)
+
+		err := p.save()
+		if err != nil {
+			if p.errorFunc != nil {
+				p.errorFunc(err)
+			}
+		} else {
+			s.remaining = p.reserve
+		}
+	}
+
+	value := s.current
+	s.current = p.advance(s.current, 1)
+	if s.remaining > 0 {
+		s.remaining--
+	}
+
+	return value
+}
+
+// advance returns the number n places after value, wrapping around after max
+func (p *FileProvider) advance(value, n int) int {
+	size := p.max - p.min + 1
+	return p.min + (value-p.min+n)%size
+}
+
+// save replaces the file with the states of the sequences
+func (p *FileProvider) save() error {
+	byteValue, err := json.MarshalIndent(p.states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteFile, err)
+	}
+
+	dir := filepath.Dir(p.path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(p.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteFile, err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	_, err = tmp.Write(byteValue)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), p.path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteFile, err)
+	}
+
+	// The rename is durable once the directory is synced, not every platform allows it
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+
+	return nil
+}
+
+type fileSequence struct {
+	provider *FileProvider
+	key      string
+}
+
+func (s *fileSequence) Next() int {
+	return s.provider.Next(s.key)
+}
@@ -0,0 +1,201 @@
+package sequence
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func numbers(seq Sequence, n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = seq.Next()
+	}
+
+	return values
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func readStates(t *testing.T, path string) map[string]state {
+	t.Helper()
+
+	byteValue, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	var states map[string]state
+	err = json.Unmarshal(byteValue, &states)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	return states
+}
+
+func TestFileProviderReserve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequences.json")
+
+	p, err := NewFileProvider(path, WithReserve(10))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	if got := numbers(p.Sequence("00000001"), 3); !equal(got, []int{1, 2, 3}) {
+		t.Errorf("numbers %v", got)
+	}
+
+	// the file records the end of the block, not the last number
+	if next := readStates(t, path)["00000001"].Next; next != 11 {
+		t.Errorf("next in the file is %d, want 11", next)
+	}
+
+	// after a restart the numbers left in the block are skipped
+	p, err = NewFileProvider(path, WithReserve(10))
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+
+	seq := p.Sequence("00000001")
+	if got := numbers(seq, 10); !equal(got, []int{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}) {
+		t.Errorf("numbers after a restart %v", got)
+	}
+
+	if next := readStates(t, path)["00000001"].Next; next != 21 {
+		t.Errorf("next in the file is %d, want 21", next)
+	}
+
+	if got := seq.Next(); got != 21 || readStates(t, path)["00000001"].Next != 31 {
+		t.Errorf("the next block starts at %d", got)
+	}
+
+	if got := p.Next("00000002"); got != 1 {
+		t.Errorf("another key starts at %d", got)
+	}
+}
+
+func TestFileProviderWraparound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequences.json")
+
+	p, err := NewFileProvider(path, WithRange(1, 3), WithReserve(2))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	if got := numbers(p.Sequence("host"), 5); !equal(got, []int{1, 2, 3, 1, 2}) {
+		t.Errorf("numbers %v", got)
+	}
+
+	// a number of the file outside the range starts again from the minimum
+	p, err = NewFileProvider(path, WithRange(5, 9))
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+
+	if got := p.Next("host"); got != 5 {
+		t.Errorf("number out of the range %d, want 5", got)
+	}
+}
+
+func TestFileProviderDailyReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequences.json")
+
+	location := time.FixedZone("ART", -3*60*60)
+	now := time.Date(2024, time.March, 5, 23, 0, 0, 0, location)
+	clock := func() time.Time {
+		return now
+	}
+
+	p, err := NewFileProvider(path, WithDailyReset(location), WithClock(clock), WithReserve(5))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	seq := p.Sequence("00000001")
+	if got := numbers(seq, 2); !equal(got, []int{1, 2}) {
+		t.Errorf("numbers %v", got)
+	}
+
+	// the same day after a restart continues the sequence
+	p, err = NewFileProvider(path, WithDailyReset(location), WithClock(clock), WithReserve(5))
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+
+	seq = p.Sequence("00000001")
+	if got := seq.Next(); got != 6 {
+		t.Errorf("number after a restart %d, want 6", got)
+	}
+
+	// midnight is of the location, not UTC
+	now = now.Add(30 * time.Minute)
+	if got := seq.Next(); got != 7 {
+		t.Errorf("number before midnight %d, want 7", got)
+	}
+
+	now = now.Add(time.Hour)
+	if got := numbers(seq, 2); !equal(got, []int{1, 2}) {
+		t.Errorf("numbers of the next day %v", got)
+	}
+
+	if date := readStates(t, path)["00000001"].Date; date != "20240306" {
+		t.Errorf("date in the file is %s, want 20240306", date)
+	}
+}
+
+func TestFileProviderWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "sequences.json")
+
+	var errs []error
+	p, err := NewFileProvider(path, WithErrorFunc(func(err error) {
+		errs = append(errs, err)
+	}))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	// the sequence keeps going in memory and the write is retried on every number
+	if got := numbers(p.Sequence("host"), 2); !equal(got, []int{1, 2}) {
+		t.Errorf("numbers %v", got)
+	}
+
+	if len(errs) != 2 || !errors.Is(errs[0], ErrWriteFile) {
+		t.Errorf("errors %v, want two %v", errs, ErrWriteFile)
+	}
+}
+
+func TestNewFileProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewFileProvider(filepath.Join(dir, "sequences.json"), WithRange(10, 1))
+	if !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("new returned %v, want %v", err, ErrInvalidRange)
+	}
+
+	path := filepath.Join(dir, "invalid.json")
+	err = os.WriteFile(path, []byte("{"), 0644)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	_, err = NewFileProvider(path)
+	if !errors.Is(err, ErrReadFile) {
+		t.Errorf("new returned %v, want %v", err, ErrReadFile)
+	}
+}
@@ -0,0 +1,48 @@
+// Package sequence provides the numbers of the STAN (DE11) and RRN (DE37) of the messages.
+// A Provider keeps a separate sequence for every key, e.g. a terminal ID or the name of
+// an upstream link, and FileProvider persists them so a restart does not repeat numbers.
+package sequence
+
+import (
+	"github.com/tomasdemarco/iso8583/utils"
+	"sync"
+)
+
+// Sequence returns consecutive numbers, it must be safe for concurrent use.
+// utils.Stan is a Sequence.
+type Sequence interface {
+	Next() int
+}
+
+// Provider returns the sequence of a key, creating it when it does not exist
+type Provider interface {
+	Sequence(key string) Sequence
+}
+
+// MemoryProvider keeps the sequences in memory, they start again from 1 on every restart
+type MemoryProvider struct {
+	mu        sync.Mutex
+	max       int
+	sequences map[string]Sequence
+}
+
+// NewMemoryProvider returns a provider whose sequences go from 1 to max and then wrap around
+func NewMemoryProvider(max int) *MemoryProvider {
+	return &MemoryProvider{
+		max:       max,
+		sequences: make(map[string]Sequence),
+	}
+}
+
+func (p *MemoryProvider) Sequence(key string) Sequence {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seq, ok := p.sequences[key]
+	if !ok {
+		seq = utils.NewStan(1, p.max)
+		p.sequences[key] = seq
+	}
+
+	return seq
+}
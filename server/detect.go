@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/header"
+	"github.com/tomasdemarco/iso8583/packager"
+)
+
+// Dialect is a packager and the header codec of its messages, for the connections whose
+// first frame matches
+type Dialect struct {
+	Name             string
+	Packager         *packager.Packager
+	PackagerFunc     func() *packager.Packager
+	HeaderPackFunc   header.PackFunc
+	HeaderUnpackFunc header.UnpackFunc
+	// Match reports whether the first bytes of the first frame of a connection, length prefix
+	// included, are of the dialect. A nil Match matches every frame.
+	Match func(first []byte) bool
+}
+
+// packager returns the current version of the packager of the dialect
+func (d *Dialect) packager() *packager.Packager {
+	if d.PackagerFunc != nil {
+		if pkg := d.PackagerFunc(); pkg != nil {
+			return pkg
+		}
+	}
+
+	return d.Packager
+}
+
+// Detector picks the dialect of each connection from the first Size bytes of its first frame,
+// or the whole frame when it is shorter
+type Detector struct {
+	Size     int
+	Dialects []Dialect
+}
+
+// NewDetector returns a detector that tries dialects in order on the first size bytes of a connection
+func NewDetector(size int, dialects ...Dialect) *Detector {
+	return &Detector{
+		Size:     size,
+		Dialects: dialects,
+	}
+}
+
+// Detect returns the first dialect that matches first
+func (d *Detector) Detect(first []byte) (*Dialect, error) {
+	for i := range d.Dialects {
+		if d.Dialects[i].Match == nil || d.Dialects[i].Match(first) {
+			return &d.Dialects[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %X", ErrUnknownDialect, first)
+}
+
+// MatchBytes matches the frames with value at offset, e.g. the TPDU id 0x60 after a 2 bytes length prefix
+func MatchBytes(offset int, value []byte) func(first []byte) bool {
+	return func(first []byte) bool {
+		return len(first) >= offset+len(value) && bytes.Equal(first[offset:offset+len(value)], value)
+	}
+}
+
+// MatchDigits matches the frames with n ASCII digits at offset, e.g. an ASCII MTI
+func MatchDigits(offset int, n int) func(first []byte) bool {
+	return func(first []byte) bool {
+		if len(first) < offset+n {
+			return false
+		}
+
+		for _, b := range first[offset : offset+n] {
+			if b < '0' || b > '9' {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// WithDetector picks the packager and header codec of each connection with d when its first frame
+// arrives. The connection keeps them until it closes and its dialect is recorded in the
+// "dialect" attribute of the connection. A connection that matches no dialect is closed.
+func WithDetector(d *Detector) Option {
+	return func(s *Server) {
+		s.Detector = d
+	}
+}
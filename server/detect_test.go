@@ -0,0 +1,80 @@
+package server_test
+
+import (
+	"bufio"
+	"errors"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/iso8583/length"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	detector := server.NewDetector(8,
+		server.Dialect{Name: "tpdu", Match: server.MatchBytes(2, []byte{0x60})},
+		server.Dialect{Name: "ascii", Match: server.MatchDigits(2, 4)},
+	)
+
+	tests := []struct {
+		first []byte
+		want  string
+	}{
+		{[]byte{0x00, 0x20, 0x60, 0x00, 0x01}, "tpdu"},
+		{[]byte("\x00\x200200"), "ascii"},
+		{[]byte("\x00\x20020"), ""},
+		{[]byte{0x00, 0x20, 0x02, 0x00}, ""},
+	}
+
+	for _, tt := range tests {
+		dialect, err := detector.Detect(tt.first)
+		if tt.want == "" {
+			if !errors.Is(err, server.ErrUnknownDialect) {
+				t.Errorf("detect %X returned %v, want %v", tt.first, err, server.ErrUnknownDialect)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("detect %X: %v", tt.first, err)
+		} else if dialect.Name != tt.want {
+			t.Errorf("detect %X returned %s, want %s", tt.first, dialect.Name, tt.want)
+		}
+	}
+
+	fallback := server.NewDetector(8, server.Dialect{Name: "tpdu", Match: server.MatchBytes(2, []byte{0x60})}, server.Dialect{Name: "default"})
+	if dialect, err := fallback.Detect([]byte{0x00}); err != nil || dialect.Name != "default" {
+		t.Errorf("the dialect without Match did not match")
+	}
+}
+
+// TestDetectShortFrame checks that a first frame shorter than the size of the detector is detected
+// and answered, instead of waiting for more bytes
+func TestDetectShortFrame(t *testing.T) {
+	pkg := loadPackager(t)
+	detector := server.NewDetector(4096, server.Dialect{Name: "default"})
+
+	h := gopostest.New(t, pkg, approve, gopostest.WithServerOptions(server.WithDetector(detector)))
+
+	conn := h.Dial()
+	defer conn.Close()
+
+	frame := h.Frame(gopostest.NewMessage(pkg, map[int]string{0: "0200", 3: "000000", 11: "000001"}))
+	_, err := conn.Write(frame)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	reader := bufio.NewReader(conn)
+	n, err := length.Unpack(reader, pkg.Prefix)
+	if err != nil {
+		t.Fatalf("read length: %v", err)
+	}
+
+	_, err = io.ReadFull(reader, make([]byte, n))
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+}
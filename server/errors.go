@@ -0,0 +1,10 @@
+package server
+
+import "errors"
+
+var (
+	ErrServerClosed   = errors.New("server closed")
+	ErrListeners      = errors.New("listeners do not match the servers")
+	ErrMissingAddr    = errors.New("listener without address")
+	ErrUnknownDialect = errors.New("no dialect matches the first frame")
+)
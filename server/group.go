@@ -0,0 +1,124 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tomasdemarco/iso8583/packager"
+	"net"
+	"sync"
+)
+
+// Listener is the definition of one listener of a Group: the address it binds, the packager
+// and handler of its messages and the options of its server, e.g. WithHeader, WithLength
+// or WithMaxClients for its framing and connection limit
+type Listener struct {
+	Name     string
+	Addr     string
+	Packager *packager.Packager
+	Handler  HandlerFunc
+	Options  []Option
+}
+
+// Group runs a server for each of its listeners with one lifecycle: Run starts them all
+// and Close stops them all
+type Group struct {
+	Servers []*Server
+}
+
+// NewGroup returns a group with a server for each listener. The options of the group, e.g. WithLogger,
+// WithJournal or WithTraceExporter, are shared by every server and are applied before the options
+// of each listener. It returns ErrMissingAddr when a listener has no address.
+func NewGroup(listeners []Listener, opts ...Option) (*Group, error) {
+	g := Group{
+		Servers: make([]*Server, 0, len(listeners)),
+	}
+
+	for i, l := range listeners {
+		if l.Addr == "" {
+			return nil, fmt.Errorf("%w: listener %d %s", ErrMissingAddr, i, l.Name)
+		}
+
+		serverOpts := make([]Option, 0, len(opts)+len(l.Options)+2)
+		serverOpts = append(serverOpts, opts...)
+		serverOpts = append(serverOpts, WithAddr(l.Addr))
+		if l.Name != "" {
+			serverOpts = append(serverOpts, WithName(l.Name))
+		}
+		serverOpts = append(serverOpts, l.Options...)
+
+		g.Servers = append(g.Servers, New(0, l.Packager, l.Handler, serverOpts...))
+	}
+
+	return &g, nil
+}
+
+// Server returns the server of the listener name
+func (g *Group) Server(name string) (*Server, bool) {
+	for _, s := range g.Servers {
+		if s.Name == name {
+			return s, true
+		}
+	}
+
+	return nil, false
+}
+
+// Run binds the address of every listener and serves them until Close is called.
+// When an address cannot be bound, no listener is served.
+func (g *Group) Run() error {
+	listeners := make([]net.Listener, 0, len(g.Servers))
+	for _, s := range g.Servers {
+		listener, err := s.listen()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return g.Serve(listeners)
+}
+
+// Serve accepts the clients of each listener with the server in the same position until Close is called
+func (g *Group) Serve(listeners []net.Listener) error {
+	if len(listeners) != len(g.Servers) {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+
+		return fmt.Errorf("%w: %d listeners for %d servers", ErrListeners, len(listeners), len(g.Servers))
+	}
+
+	errs := make([]error, len(g.Servers))
+	var wg sync.WaitGroup
+	for i, s := range g.Servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.Serve(listeners[i])
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Close stops every server of the group and waits for the requests that are being handled
+func (g *Group) Close() error {
+	errs := make([]error, len(g.Servers))
+	var wg sync.WaitGroup
+	for i, s := range g.Servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.Close()
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"errors"
+	"github.com/tomasdemarco/go-pos/client"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/server"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestNewGroupMissingAddr(t *testing.T) {
+	pkg := loadPackager(t)
+
+	_, err := server.NewGroup([]server.Listener{
+		{Name: "pos", Addr: ":8015", Packager: pkg, Handler: approve},
+		{Name: "host", Packager: pkg, Handler: approve},
+	})
+	if !errors.Is(err, server.ErrMissingAddr) {
+		t.Errorf("new group returned %v, want %v", err, server.ErrMissingAddr)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	pkg := loadPackager(t)
+
+	// each listener answers with its own response code
+	respond := func(responseCode string) server.HandlerFunc {
+		return func(c *ctx.RequestContext, srv *server.Server) {
+			response, err := srv.NewResponse(c.Request)
+			if err != nil {
+				return
+			}
+
+			response.SetField(39, responseCode)
+			_ = srv.SendResponse(c, response)
+		}
+	}
+
+	g, err := server.NewGroup([]server.Listener{
+		{Name: "pos", Addr: ":8015", Packager: pkg, Handler: respond("00")},
+		{Name: "host", Addr: ":8016", Packager: pkg, Handler: respond("05")},
+	}, server.WithLogger(logger.New(logger.Info, "group", logger.WithWriter(io.Discard))))
+	if err != nil {
+		t.Fatalf("new group: %v", err)
+	}
+
+	if s, ok := g.Server("host"); !ok || s.Addr != ":8016" {
+		t.Fatalf("server host not found")
+	}
+
+	if _, ok := g.Server("atm"); ok {
+		t.Errorf("found a server for an unknown listener")
+	}
+
+	listeners := []*gopostest.Listener{gopostest.NewListener(), gopostest.NewListener()}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- g.Serve([]net.Listener{listeners[0], listeners[1]})
+	}()
+
+	for i, want := range []string{"00", "05"} {
+		cli := client.New("pipe", 0, pkg,
+			client.WithDialer(listeners[i].Dial),
+			client.WithAutoReconnect(false),
+			client.WithMatchFields([]int{11}),
+			client.WithLogger(logger.New(logger.Info, "client", logger.WithWriter(io.Discard))),
+		)
+
+		err = cli.Connect()
+		if err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+
+		msg := gopostest.NewMessage(pkg, map[int]string{0: "0200", 3: "000000", 11: "000001"})
+		response, err := cli.Do(ctx.NewRequestContext(nil, msg), msg)
+		_ = cli.Disconnect()
+		if err != nil {
+			t.Fatalf("do: %v", err)
+		}
+
+		gopostest.AssertResponseCode(t, response, want)
+	}
+
+	err = g.Close()
+	if err != nil {
+		t.Errorf("close: %v", err)
+	}
+
+	err = <-served
+	if err != nil {
+		t.Errorf("serve: %v", err)
+	}
+}
+
+func TestGroupServeListeners(t *testing.T) {
+	pkg := loadPackager(t)
+
+	g, err := server.NewGroup([]server.Listener{{Name: "pos", Addr: ":8015", Packager: pkg, Handler: approve}})
+	if err != nil {
+		t.Fatalf("new group: %v", err)
+	}
+
+	err = g.Serve(nil)
+	if !errors.Is(err, server.ErrListeners) {
+		t.Errorf("serve returned %v, want %v", err, server.ErrListeners)
+	}
+}
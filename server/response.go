@@ -0,0 +1,99 @@
+package server
+
+import (
+	"github.com/tomasdemarco/go-pos/mti"
+	"github.com/tomasdemarco/iso8583/message"
+)
+
+// ResponseProfile says how the response to a request is built from it
+type ResponseProfile struct {
+	// Echo are the fields copied from the request, every field when it is empty
+	Echo []int
+	// Drop are the fields of the request that are never copied
+	Drop []int
+	// Add are the fields set in the response
+	Add map[int]string
+}
+
+// DefaultResponseProfile echoes every field of the request except the card secrets:
+// track 2 (DE35), track 1 (DE45) and PIN block (DE52)
+var DefaultResponseProfile = ResponseProfile{
+	Drop: []int{35, 45, 52},
+}
+
+// DefaultResponseProfiles are the profiles by request MTI used by NewResponse,
+// the MTIs without a profile use DefaultResponseProfile
+var DefaultResponseProfiles = map[string]ResponseProfile{
+	"0800": {Echo: []int{3, 7, 11, 12, 13, 24, 41, 42, 53, 70}},
+	"1804": {Echo: []int{3, 7, 11, 12, 24, 41, 42, 53, 93, 94}},
+}
+
+// WithResponseProfiles sets the profiles by request MTI used by Server.NewResponse
+func WithResponseProfiles(profiles map[string]ResponseProfile) Option {
+	return func(s *Server) {
+		s.ResponseProfiles = profiles
+	}
+}
+
+// NewResponse returns the response to req with the response MTI and the fields of the
+// profile of its MTI in DefaultResponseProfiles
+func NewResponse(req *message.Message) (*message.Message, error) {
+	return newResponse(req, DefaultResponseProfiles)
+}
+
+// NewResponse returns the response to req with the response MTI and the fields of the
+// profile of its MTI in the response profiles of the server
+func (s *Server) NewResponse(req *message.Message) (*message.Message, error) {
+	return newResponse(req, s.ResponseProfiles)
+}
+
+// newResponse builds the response to req. Repeats use the profile of the original MTI,
+// e.g. 0201 the one of 0200.
+func newResponse(req *message.Message, profiles map[string]ResponseProfile) (*message.Message, error) {
+	reqMti, err := req.GetField(0)
+	if err != nil {
+		return nil, err
+	}
+
+	resMti, err := mti.Response(reqMti)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, ok := profiles[mti.Original(reqMti)]
+	if !ok {
+		profile = DefaultResponseProfile
+	}
+
+	drop := make(map[int]bool, len(profile.Drop)+1)
+	drop[1] = true
+	for _, fieldId := range profile.Drop {
+		drop[fieldId] = true
+	}
+
+	res := message.NewMessage(req.Packager)
+	res.Header = req.Header
+	res.Trailer = req.Trailer
+
+	if len(profile.Echo) == 0 {
+		for fieldId, value := range req.Fields {
+			if !drop[fieldId] {
+				res.SetField(fieldId, value)
+			}
+		}
+	} else {
+		for _, fieldId := range profile.Echo {
+			if value, ok := req.Fields[fieldId]; ok && !drop[fieldId] {
+				res.SetField(fieldId, value)
+			}
+		}
+	}
+
+	for fieldId, value := range profile.Add {
+		res.SetField(fieldId, value)
+	}
+
+	res.SetField(0, resMti)
+
+	return res, nil
+}
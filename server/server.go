@@ -6,8 +6,12 @@ import (
 	"fmt"
 	ctx "github.com/tomasdemarco/go-pos/context"
 	"github.com/tomasdemarco/go-pos/header"
+	"github.com/tomasdemarco/go-pos/journal"
 	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/sequence"
+	"github.com/tomasdemarco/go-pos/trace"
 	"github.com/tomasdemarco/go-pos/trailer"
+	"github.com/tomasdemarco/go-pos/validate"
 	"github.com/tomasdemarco/iso8583/length"
 	"github.com/tomasdemarco/iso8583/message"
 	"github.com/tomasdemarco/iso8583/packager"
@@ -15,6 +19,7 @@ import (
 	"io"
 	"net"
 	"runtime/debug"
+	"sync"
 	"time"
 )
 
@@ -22,9 +27,14 @@ type Server struct {
 	Name                 string
 	Network              string
 	Port                 int
+	Addr                 string
 	Packager             *packager.Packager
-	Stan                 *utils.Stan
+	PackagerFunc         func() *packager.Packager
+	Stan                 sequence.Sequence
 	Logger               *logger.Logger
+	TraceExporter        trace.Exporter
+	AttributeExtractor   ctx.Extractor
+	Journal              journal.Journal
 	HandlerFunc          func(c *ctx.RequestContext)
 	LengthPackFunc       length.PackFunc
 	LengthUnpackFunc     length.UnpackFunc
@@ -33,14 +43,24 @@ type Server struct {
 	TrailerPackFunc      trailer.PackFunc
 	TrailerUnpackFunc    trailer.UnpackFunc
 	TrailerGetLengthFunc trailer.GetLengthFunc
+	ResponseProfiles     map[string]ResponseProfile
+	Validation           ValidationMode
+	Detector             *Detector
 
 	maxClients         int
 	sem                chan struct{}
+	mu                 sync.Mutex
+	wg                 sync.WaitGroup
+	listener           net.Listener
+	clients            map[*ctx.ClientContext]*Dialect
+	closed             bool
 	ReadClientTimeout  time.Duration
 	ReadMessageTimeout time.Duration
 	MaxMessageSize     int
 }
 
+// HandlerFunc handles a request of a client. A request the handler returns from without calling
+// SendResponse is logged with the NoResponse outcome.
 type HandlerFunc func(*ctx.RequestContext, *Server)
 
 type Option func(*Server)
@@ -57,6 +77,76 @@ func WithLogger(logger *logger.Logger) Option {
 	}
 }
 
+// WithTraceExporter exports the span of each request when its response is sent
+func WithTraceExporter(exporter trace.Exporter) Option {
+	return func(s *Server) {
+		s.TraceExporter = exporter
+	}
+}
+
+// WithAttributeExtractor sets the extractor that adds the content of each received message
+// to the attributes of its request context
+func WithAttributeExtractor(extractor ctx.Extractor) Option {
+	return func(s *Server) {
+		s.AttributeExtractor = extractor
+	}
+}
+
+// WithJournal records every received and sent message in j
+func WithJournal(j journal.Journal) Option {
+	return func(s *Server) {
+		s.Journal = j
+	}
+}
+
+// WithPackagerFunc takes the packager of each connection from f when it is accepted, e.g. the
+// current version of a packager of a registry. A connection keeps that packager until it closes,
+// and a request keeps it until its response is sent. Packager is used when f returns nil.
+func WithPackagerFunc(f func() *packager.Packager) Option {
+	return func(s *Server) {
+		s.PackagerFunc = f
+	}
+}
+
+// WithAddr binds the server to addr, e.g. "10.0.0.1:8015", instead of the port on every interface
+func WithAddr(addr string) Option {
+	return func(s *Server) {
+		s.Addr = addr
+	}
+}
+
+// WithHeader sets the functions that pack and unpack the header of the messages, e.g. header.Fixed
+func WithHeader(pack header.PackFunc, unpack header.UnpackFunc) Option {
+	return func(s *Server) {
+		s.HeaderPackFunc = pack
+		s.HeaderUnpackFunc = unpack
+	}
+}
+
+// WithLength sets the functions that pack and unpack the length prefix of the messages
+func WithLength(pack length.PackFunc, unpack length.UnpackFunc) Option {
+	return func(s *Server) {
+		s.LengthPackFunc = pack
+		s.LengthUnpackFunc = unpack
+	}
+}
+
+// WithTrailer sets the functions that pack, unpack and measure the trailer of the messages
+func WithTrailer(pack trailer.PackFunc, unpack trailer.UnpackFunc, getLength trailer.GetLengthFunc) Option {
+	return func(s *Server) {
+		s.TrailerPackFunc = pack
+		s.TrailerUnpackFunc = unpack
+		s.TrailerGetLengthFunc = getLength
+	}
+}
+
+// WithStan takes the STAN of the messages started by the server from seq
+func WithStan(seq sequence.Sequence) Option {
+	return func(s *Server) {
+		s.Stan = seq
+	}
+}
+
 func WithMaxClients(max int) Option {
 	return func(s *Server) {
 		s.maxClients = max
@@ -97,6 +187,7 @@ func New(
 		Packager:             packager,
 		Stan:                 utils.NewStan(1, 999999),
 		Logger:               logger.New(logger.Info, "server"),
+		AttributeExtractor:   ctx.DefaultExtractor,
 		LengthPackFunc:       length.Pack,
 		LengthUnpackFunc:     length.Unpack,
 		HeaderPackFunc:       header.Pack,
@@ -104,8 +195,10 @@ func New(
 		TrailerPackFunc:      trailer.Pack,
 		TrailerUnpackFunc:    trailer.Unpack,
 		TrailerGetLengthFunc: trailer.GetLength,
+		ResponseProfiles:     DefaultResponseProfiles,
 		maxClients:           10, // Default max clients
 		sem:                  make(chan struct{}, 10),
+		clients:              make(map[*ctx.ClientContext]*Dialect),
 		ReadClientTimeout:    10 * time.Minute,
 		ReadMessageTimeout:   10 * time.Second,
 		MaxMessageSize:       4096,
@@ -124,42 +217,99 @@ func New(
 }
 
 func (s *Server) Run() error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// listen binds Addr, or Port on every interface when Addr is empty
+func (s *Server) listen() (net.Listener, error) {
+	addr := s.Addr
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", s.Port)
+	}
+
 	//Inicia a escuchar clientes
-	listener, err := net.Listen(s.Network, fmt.Sprintf(":%d", s.Port))
+	listener, err := net.Listen(s.Network, addr)
 	if err != nil {
 		s.Logger.Error(nil, errors.New(fmt.Sprintf("error listening: err %v", err)))
-		return err
+		return nil, err
 	}
 
-	s.Logger.Info(nil, logger.Message, fmt.Sprintf("listening on port %d", s.Port))
+	s.Logger.Info(nil, logger.Message, fmt.Sprintf("listening on %s", listener.Addr().String()))
+
+	return listener, nil
+}
+
+// Serve accepts the clients of listener until Close is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
 
 	//Escucha a los clientes
 	s.listenClient(listener)
 
-	//Cierra las conexiones
-	defer func() {
-		err = listener.Close()
-		if err != nil {
-			s.Logger.Error(nil, errors.New(fmt.Sprintf("error finish listen on port %d: %v", s.Port, err)))
-		}
-		s.Logger.Info(nil, logger.Message, fmt.Sprintf("finish listen on port %d", s.Port))
-	}()
+	s.Logger.Info(nil, logger.Message, fmt.Sprintf("finish listen on %s", listener.Addr().String()))
 
 	return nil
 }
 
+// Close stops accepting clients, closes the connection of every client
+// and waits for the requests that are being handled
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	listener := s.listener
+	clients := make([]*ctx.ClientContext, 0, len(s.clients))
+	for clientCtx := range s.clients {
+		clients = append(clients, clientCtx)
+	}
+	s.mu.Unlock()
+
+	var err error
+	if listener != nil {
+		err = listener.Close()
+	}
+
+	for _, clientCtx := range clients {
+		_ = clientCtx.Conn.Close()
+	}
+
+	s.wg.Wait()
+
+	return err
+}
+
 // Realiza el accept a cada cliente que intenta conectarse
 func (s *Server) listenClient(listener net.Listener) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			s.Logger.Info(nil, logger.Message, fmt.Sprintf("connection refused to %s", conn.RemoteAddr().String()))
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
 			s.Logger.Error(nil, errors.New(fmt.Sprintf("err accept: %v", err)))
 		} else {
 			select {
 			case s.sem <- struct{}{}: // Intenta adquirir el semáforo
 				clientCtx := ctx.NewClientContext(conn)
 
+				if !s.addClient(clientCtx) {
+					_ = conn.Close()
+					<-s.sem
+					return
+				}
+
 				s.Logger.Info(nil, logger.Message, fmt.Sprintf("connection established to %s (%s)", conn.RemoteAddr().String(), clientCtx.Id.String()))
 				s.Logger.Info(nil, logger.Message, fmt.Sprintf("accept local port %s / remote host %s (%s)", conn.LocalAddr().String(), conn.RemoteAddr().String(), clientCtx.Id.String()))
 
@@ -175,6 +325,29 @@ func (s *Server) listenClient(listener net.Listener) {
 	}
 }
 
+// addClient registers a connected client, or returns false when the server is closed
+func (s *Server) addClient(clientCtx *ctx.ClientContext) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.clients[clientCtx] = nil
+	s.wg.Add(1)
+
+	return true
+}
+
+func (s *Server) removeClient(clientCtx *ctx.ClientContext) {
+	s.mu.Lock()
+	delete(s.clients, clientCtx)
+	s.mu.Unlock()
+
+	s.wg.Done()
+}
+
 // Maneja los clientes que se conectan al switch
 func (s *Server) handleClient(clientCtx *ctx.ClientContext) {
 	defer func() {
@@ -191,17 +364,45 @@ func (s *Server) handleClient(clientCtx *ctx.ClientContext) {
 		s.Logger.Info(clientCtx, logger.Message, fmt.Sprintf("disconnection to %s", clientCtx.RemoteAddr))
 		err := clientCtx.Conn.Close()
 		<-s.sem
-		if err != nil {
+		s.removeClient(clientCtx)
+		if err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
 			s.Logger.Error(clientCtx, errors.New(fmt.Sprintf("disconnection to %s: %v", clientCtx.RemoteAddr, err)))
 			return
 		}
 	}()
 
+	pkg := s.packager()
+	headerUnpackFunc := s.HeaderUnpackFunc
+
+	if s.Detector != nil {
+		_ = clientCtx.Conn.SetReadDeadline(time.Now().Add(s.ReadClientTimeout))
+		dialect, err := s.detect(clientCtx, pkg)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
+				s.Logger.Error(clientCtx, err)
+			}
+			return
+		}
+
+		if dialectPkg := dialect.packager(); dialectPkg != nil {
+			pkg = dialectPkg
+		}
+		if dialect.HeaderUnpackFunc != nil {
+			headerUnpackFunc = dialect.HeaderUnpackFunc
+		}
+	}
+
+	// the permissive copy of the packager lives as long as the connection that pinned it
+	var permissive *packager.Packager
+	if s.Validation != ValidationOff {
+		permissive = validate.Permissive(pkg)
+	}
+
 	for {
 		_ = clientCtx.Conn.SetReadDeadline(time.Now().Add(s.ReadClientTimeout))
-		lengthVal, err := s.LengthUnpackFunc(clientCtx.Reader, s.Packager.Prefix)
+		lengthVal, err := s.LengthUnpackFunc(clientCtx.Reader, pkg.Prefix)
 		if err != nil {
-			if err != io.EOF {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
 				s.Logger.Error(clientCtx, err)
 			}
 			break
@@ -216,13 +417,16 @@ func (s *Server) handleClient(clientCtx *ctx.ClientContext) {
 			return
 		}
 
-		msgReq := message.NewMessage(s.Packager)
+		msgReq := message.NewMessage(pkg)
 		c := ctx.NewRequestContext(clientCtx, msgReq)
+		c.Span.Name = s.Name
+		c.Span.SetAttribute("connId", clientCtx.Id.String())
+		c.Span.Begin(trace.PhaseRead)
 
 		s.Logger.Debug(c, fmt.Sprintf("received message length: %d", lengthVal))
 
 		msgReq.Length = lengthVal
-		headerVal, headerLength, err := s.HeaderUnpackFunc(clientCtx.Reader)
+		headerVal, headerLength, err := headerUnpackFunc(clientCtx.Reader)
 		if err != nil {
 			if err != io.EOF {
 				s.Logger.Error(c, err)
@@ -240,8 +444,14 @@ func (s *Server) handleClient(clientCtx *ctx.ClientContext) {
 			}
 		}
 
+		bodyLength := lengthVal - headerLength - s.TrailerGetLengthFunc()
+		if bodyLength <= 0 {
+			s.Logger.Error(c, errors.New(fmt.Sprintf("invalid received message length (%d), shorter than header and trailer", lengthVal)))
+			break
+		}
+
 		_ = clientCtx.Conn.SetReadDeadline(time.Now().Add(s.ReadMessageTimeout))
-		msgRaw := make([]byte, lengthVal-headerLength-s.TrailerGetLengthFunc())
+		msgRaw := make([]byte, bodyLength)
 		_, err = io.ReadFull(clientCtx.Reader, msgRaw)
 		if err != nil {
 			if err != io.EOF {
@@ -250,19 +460,7 @@ func (s *Server) handleClient(clientCtx *ctx.ClientContext) {
 			break
 		}
 
-		s.Logger.Debug(c, fmt.Sprintf("received a message: %X", msgRaw))
-
-		err = msgReq.Unpack(msgRaw)
-		if err != nil {
-			s.Logger.Error(c, err)
-		} else {
-
-			s.Logger.Info(c, logger.IsoUnpack, fmt.Sprintf("%X", msgRaw))
-			s.Logger.Info(c, logger.IsoMessage, msgReq.Log())
-
-			go s.HandlerFunc(c)
-		}
-
+		// the trailer is read before the handler runs, as the handler reads it for the response
 		trailerVal, _, err := s.TrailerUnpackFunc(clientCtx.Reader)
 		if err != nil {
 			if err != io.EOF {
@@ -280,26 +478,211 @@ func (s *Server) handleClient(clientCtx *ctx.ClientContext) {
 				s.Logger.Debug(c, fmt.Sprintf("received message trailer: %v", msgReq.Trailer))
 			}
 		}
+
+		c.Span.Finish(trace.PhaseRead)
+		c.Span.Begin(trace.PhaseUnpack)
+		err = s.unpack(msgReq, permissive, msgRaw)
+		c.Span.Finish(trace.PhaseUnpack)
+
+		var invalid validate.Errors
+		if errors.As(err, &invalid) {
+			c.ValidationErr = err
+			err = nil
+		}
+
+		if err != nil {
+			s.Logger.Debug(c, fmt.Sprintf("received a message: %s", s.Logger.MessageHex(nil, msgRaw)))
+			s.Logger.Error(c, err)
+
+			c.Span.SetAttribute("error", err.Error())
+			s.stopSpan(c)
+		} else {
+			c.Extract(s.AttributeExtractor)
+			s.record(c, journal.Received, msgReq, msgRaw)
+
+			s.Logger.Debug(c, fmt.Sprintf("received a message: %s", s.Logger.MessageHex(msgReq, msgRaw)))
+			s.Logger.Info(c, logger.IsoUnpack, s.Logger.MessageHex(msgReq, msgRaw))
+			s.Logger.Info(c, logger.IsoMessage, s.Logger.MessageLog(msgReq))
+
+			if mti, err := msgReq.GetField(0); err == nil {
+				c.Span.SetAttribute("mti", mti)
+			}
+
+			c.Span.Begin(trace.PhaseHandler)
+
+			handler := s.HandlerFunc
+			if c.ValidationErr != nil {
+				s.Logger.Warn(c, fmt.Sprintf("received a message with invalid fields: %v", c.ValidationErr))
+				c.Span.SetAttribute("validation", c.ValidationErr.Error())
+
+				if s.Validation == ValidationReject {
+					handler = s.rejectFormatError
+				}
+			}
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				handler(c)
+				s.finish(c)
+			}()
+		}
 	}
 }
 
 // SendResponse message for the connection to the client
 func (s *Server) SendResponse(ctx *ctx.RequestContext, msg *message.Message) error {
+	err := s.sendResponse(ctx, msg)
+	if err != nil {
+		entry := logger.NewLogEntry(ctx, msg, logger.Failed)
+		entry.Error = err.Error()
+		s.Logger.Access(ctx, entry)
+
+		ctx.Span.SetAttribute("error", err.Error())
+		ctx.Span.SetAttribute("outcome", entry.Outcome.String())
+		s.stopSpan(ctx)
+		return err
+	}
+
+	ctx.Response = msg
+	ctx.EndTime = time.Now()
+	entry := logger.NewLogEntry(ctx, msg, logger.OutcomeOf(msg))
+	s.Logger.Access(ctx, entry)
+
+	ctx.Span.SetAttribute("outcome", entry.Outcome.String())
+	s.stopSpan(ctx)
+
+	return nil
+}
+
+// finish records the requests the handler returned from without answering: their access log
+// entry has the NoResponse outcome and their span is stopped
+func (s *Server) finish(c *ctx.RequestContext) {
+	if c.Span.Stopped() {
+		return
+	}
+
+	c.EndTime = time.Now()
+	entry := logger.NewLogEntry(c, nil, logger.NoResponse)
+	s.Logger.Access(c, entry)
+
+	c.Span.SetAttribute("outcome", entry.Outcome.String())
+	s.stopSpan(c)
+}
+
+// detect picks the dialect of the connection from the first bytes of its first frame, which
+// are left in the reader, and pins it to the connection
+func (s *Server) detect(clientCtx *ctx.ClientContext, pkg *packager.Packager) (*Dialect, error) {
+	first, err := s.peekFirst(clientCtx, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	dialect, err := s.Detector.Detect(first)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.clients[clientCtx] = dialect
+	s.mu.Unlock()
+
+	clientCtx.SetAttribute("dialect", dialect.Name)
+	s.Logger.Info(clientCtx, logger.Message, fmt.Sprintf("dialect %s detected for %s", dialect.Name, clientCtx.RemoteAddr))
+
+	return dialect, nil
+}
+
+// peekFirst returns the first bytes of the first frame of the connection without consuming them:
+// Size bytes, or the whole frame when it is shorter, from its length prefix decoded with pkg.
+// When the prefix is not one of pkg, the bytes already received are returned.
+func (s *Server) peekFirst(clientCtx *ctx.ClientContext, pkg *packager.Packager) ([]byte, error) {
+	prefixLength := pkg.Prefix.GetPackedLength()
+
+	prefixRaw, err := clientCtx.Reader.Peek(prefixLength)
+	if err != nil {
+		return nil, err
+	}
+
+	size := max(prefixLength, clientCtx.Reader.Buffered())
+	if length, err := pkg.Prefix.DecodeLength(prefixRaw, 0); err == nil {
+		size = prefixLength + length
+	}
+
+	return clientCtx.Reader.Peek(min(size, s.Detector.Size))
+}
+
+// headerPackFunc returns the function that packs the header of the responses of the connection
+func (s *Server) headerPackFunc(clientCtx *ctx.ClientContext) header.PackFunc {
+	s.mu.Lock()
+	dialect := s.clients[clientCtx]
+	s.mu.Unlock()
+
+	if dialect != nil && dialect.HeaderPackFunc != nil {
+		return dialect.HeaderPackFunc
+	}
+
+	return s.HeaderPackFunc
+}
+
+// packager returns the packager for a new connection
+func (s *Server) packager() *packager.Packager {
+	if s.PackagerFunc != nil {
+		if pkg := s.PackagerFunc(); pkg != nil {
+			return pkg
+		}
+	}
+
+	return s.Packager
+}
+
+// stopSpan ends the span of the request and exports it
+func (s *Server) stopSpan(ctx *ctx.RequestContext) {
+	err := ctx.Span.Stop(s.TraceExporter)
+	if err != nil {
+		s.Logger.Error(ctx, fmt.Errorf("export span: %w", err))
+	}
+}
+
+// record appends msg to the journal when one is set
+func (s *Server) record(c ctx.Context, direction journal.Direction, msg *message.Message, msgRaw []byte) {
+	if s.Journal == nil {
+		return
+	}
+
+	err := s.Journal.Append(journal.NewEntry(s.Name, direction, c, msg, msgRaw, s.Logger.Masking))
+	if err != nil {
+		s.Logger.Error(c, fmt.Errorf("journal: %w", err))
+	}
+}
+
+func (s *Server) sendResponse(ctx *ctx.RequestContext, msg *message.Message) error {
+	ctx.Span.Finish(trace.PhaseHandler)
+	ctx.Span.Begin(trace.PhasePack)
 	msgRaw, err := msg.Pack()
 	if err != nil {
 		return err
 	}
 
-	headerRaw, headerLength, err := s.HeaderPackFunc(msg.Header)
+	headerRaw, headerLength, err := s.headerPackFunc(ctx.ClientCtx)(msg.Header)
+	if err != nil {
+		return err
+	}
+
 	trailerRaw, trailerLength, err := s.TrailerPackFunc(msg.Trailer)
+	if err != nil {
+		return err
+	}
 
-	lengthPacked, err := s.LengthPackFunc(s.Packager.Prefix, len(msgRaw)+headerLength+trailerLength)
+	lengthPacked, err := s.LengthPackFunc(msg.Packager.Prefix, len(msgRaw)+headerLength+trailerLength)
 	if err != nil {
 		return err
 	}
 
-	s.Logger.Info(ctx, logger.IsoPack, fmt.Sprintf("%X", msgRaw))
-	s.Logger.Info(ctx, logger.IsoMessage, msg.Log())
+	ctx.Span.Finish(trace.PhasePack)
+
+	s.Logger.Info(ctx, logger.IsoPack, s.Logger.MessageHex(msg, msgRaw))
+	s.Logger.Info(ctx, logger.IsoMessage, s.Logger.MessageLog(msg))
 
 	buf := new(bytes.Buffer)
 	buf.Write(lengthPacked)
@@ -307,13 +690,16 @@ func (s *Server) SendResponse(ctx *ctx.RequestContext, msg *message.Message) err
 	buf.Write(msgRaw)
 	buf.Write(trailerRaw)
 
+	ctx.Span.Begin(trace.PhaseWrite)
 	_, err = ctx.ClientCtx.Writer.Write(buf.Bytes())
 	if err != nil {
 		return err
 	}
+	ctx.Span.Finish(trace.PhaseWrite)
+
+	s.record(ctx, journal.Sent, msg, msgRaw)
 
-	s.Logger.Info(ctx, logger.Message, fmt.Sprintf("elapsed time %.3fms", float64(time.Since(ctx.StarTime).Nanoseconds())/1e6))
-	s.Logger.Debug(ctx, fmt.Sprintf("sent a response message: %X", buf.Bytes()))
+	s.Logger.Debug(ctx, fmt.Sprintf("sent a response message: %s", s.Logger.MessageHex(msg, buf.Bytes())))
 
 	return nil
 }
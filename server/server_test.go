@@ -0,0 +1,155 @@
+package server_test
+
+import (
+	"bufio"
+	"bytes"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/go-pos/trace"
+	"github.com/tomasdemarco/iso8583/length"
+	"github.com/tomasdemarco/iso8583/packager"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func loadPackager(t *testing.T) *packager.Packager {
+	t.Helper()
+
+	pkg, err := subfield.LoadFromJson("../iso8583/packager", "iso87BPackager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+// approve answers every request with response code 00
+func approve(c *ctx.RequestContext, srv *server.Server) {
+	response, err := srv.NewResponse(c.Request)
+	if err != nil {
+		return
+	}
+
+	response.SetField(39, "00")
+	_ = srv.SendResponse(c, response)
+}
+
+// fixedTrailer returns the trailer functions of a 2 bytes trailer
+func fixedTrailer() server.Option {
+	pack := func(value interface{}) ([]byte, int, error) {
+		raw, _ := value.([]byte)
+		return raw, len(raw), nil
+	}
+
+	unpack := func(r io.Reader) (interface{}, int, error) {
+		raw := make([]byte, 2)
+		_, err := io.ReadFull(r, raw)
+		return raw, 2, err
+	}
+
+	return server.WithTrailer(pack, unpack, func() int { return 2 })
+}
+
+// TestTrailer checks that the handler gets the trailer of the request, run it with -race
+func TestTrailer(t *testing.T) {
+	pkg := loadPackager(t)
+	trailer := []byte{0xCA, 0xFE}
+
+	h := gopostest.New(t, pkg, approve, gopostest.WithServerOptions(fixedTrailer()))
+
+	conn := h.Dial()
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 10; i++ {
+		msg := h.Message(map[int]string{0: "0200", 3: "000000", 4: "000000001000", 41: "00000001"})
+
+		raw, err := msg.Pack()
+		if err != nil {
+			t.Fatalf("pack: %v", err)
+		}
+
+		lengthRaw, err := length.Pack(pkg.Prefix, len(raw)+len(trailer))
+		if err != nil {
+			t.Fatalf("pack length: %v", err)
+		}
+
+		_, err = conn.Write(append(append(lengthRaw, raw...), trailer...))
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := length.Unpack(reader, pkg.Prefix)
+		if err != nil {
+			t.Fatalf("read length: %v", err)
+		}
+
+		frame := make([]byte, n)
+		_, err = io.ReadFull(reader, frame)
+		if err != nil {
+			t.Fatalf("read response: %v", err)
+		}
+
+		if !bytes.HasSuffix(frame, trailer) {
+			t.Fatalf("response %X does not end with trailer %X", frame, trailer)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the logs of the server and the client
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// TestNoResponse checks that a request the handler does not answer has an access log entry
+// and an exported span
+func TestNoResponse(t *testing.T) {
+	pkg := loadPackager(t)
+	exporter := trace.NewMemoryExporter()
+	logs := new(syncBuffer)
+
+	ignore := func(c *ctx.RequestContext, srv *server.Server) {}
+
+	h := gopostest.New(t, pkg, ignore,
+		gopostest.WithServerOptions(server.WithTraceExporter(exporter)),
+		gopostest.WithTimeout(100*time.Millisecond),
+		gopostest.WithLogOutput(logs),
+	)
+
+	_, err := h.Do(h.Message(map[int]string{0: "0200", 3: "000000", 41: "00000001"}))
+	if err == nil {
+		t.Fatalf("the unanswered request got a response")
+	}
+
+	h.Close()
+
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].Attributes["outcome"] != "noResponse" {
+		t.Fatalf("spans %v, want one with the noResponse outcome", spans)
+	}
+
+	if !strings.Contains(logs.String(), `"outcome":"noResponse"`) {
+		t.Errorf("no access log entry with the noResponse outcome in:\n%s", logs.String())
+	}
+}
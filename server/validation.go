@@ -0,0 +1,73 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/validate"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+)
+
+// ValidationMode is what the server does with the requests whose fields do not match
+// the patterns of the packager
+type ValidationMode int
+
+const (
+	// ValidationOff drops the request at the first invalid field, as the unpack of the packager does
+	ValidationOff ValidationMode = iota
+	// ValidationHandler passes the request to the handler with the invalid fields in ValidationErr
+	ValidationHandler
+	// ValidationReject answers the request with the format error response code
+	ValidationReject
+)
+
+// FormatErrorResponseCode is the response code (DE39) of the requests rejected by ValidationReject
+const FormatErrorResponseCode = "30"
+
+// WithValidation sets what the server does with the requests that have invalid fields
+func WithValidation(mode ValidationMode) Option {
+	return func(s *Server) {
+		s.Validation = mode
+	}
+}
+
+// unpack unpacks msgRaw into msg, reporting every invalid field unless validation is off.
+// permissive is the permissive copy of the packager of msg, nil when validation is off.
+func (s *Server) unpack(msg *message.Message, permissive *packager.Packager, msgRaw []byte) error {
+	if s.Validation == ValidationOff || permissive == nil {
+		return msg.Unpack(msgRaw)
+	}
+
+	return validate.Unpack(msg, permissive, msgRaw)
+}
+
+// rejectFormatError answers the request of c with the format error response code.
+// The invalid fields are not echoed, the client could not unpack them either.
+func (s *Server) rejectFormatError(c *ctx.RequestContext) {
+	response, err := s.NewResponse(c.Request)
+	if err != nil {
+		s.Logger.Error(c, fmt.Errorf("format error response: %w", err))
+		return
+	}
+
+	var invalid validate.Errors
+	if errors.As(c.ValidationErr, &invalid) {
+		fields := response.Fields
+		response = message.NewMessage(response.Packager)
+		response.Header = c.Request.Header
+		response.Trailer = c.Request.Trailer
+
+		for fieldId, value := range fields {
+			if fieldId != 1 && !invalid.Has(fieldId) {
+				response.SetField(fieldId, value)
+			}
+		}
+	}
+	response.SetField(39, FormatErrorResponseCode)
+
+	err = s.SendResponse(c, response)
+	if err != nil {
+		s.Logger.Error(c, fmt.Errorf("error trying to send format error response to the client: %w", err))
+	}
+}
@@ -0,0 +1,12 @@
+package simulator
+
+import "errors"
+
+var (
+	ErrFailedToOpenFile      = errors.New("failed to open rules file")
+	ErrFailedToUnmarshalJSON = errors.New("failed to unmarshal rules JSON")
+	ErrInvalidFieldNumber    = errors.New("invalid field number")
+	ErrInvalidDelay          = errors.New("invalid delay")
+	ErrInvalidPanRange       = errors.New("invalid PAN range, from and to must have the same length")
+	ErrNoResponses           = errors.New("rule without responses")
+)
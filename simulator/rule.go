@@ -0,0 +1,146 @@
+package simulator
+
+import (
+	"github.com/tomasdemarco/iso8583/message"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule answers the requests that satisfy every condition of Match. Each matching request
+// takes the next action of Responses, so a rule can approve the first call and decline the second.
+// The count is kept per value of StateFields, e.g. []int{2} counts the calls of each card,
+// or once for the rule when StateFields is empty. After the last action, Cycle starts over
+// from the first one, otherwise the last action is repeated.
+type Rule struct {
+	Name        string
+	Match       Match
+	Responses   []Action
+	StateFields []int
+	Cycle       bool
+}
+
+// Match holds the conditions of a rule. Empty conditions match any request.
+type Match struct {
+	// Mti lists the accepted message types
+	Mti []string `json:"mti"`
+	// ProcessingCode lists prefixes of DE3, e.g. "00" for purchases or "000000"
+	ProcessingCode []string `json:"processingCode"`
+	// PanRanges are ranges of the PAN, taken from DE2 or else from the track 2 in DE35
+	PanRanges []PanRange `json:"panRanges"`
+	// AmountMin and AmountMax bound DE4 in minor units, both inclusive
+	AmountMin *int64 `json:"amountMin"`
+	AmountMax *int64 `json:"amountMax"`
+	// Terminals lists terminal ids (DE41), compared without padding
+	Terminals []string `json:"terminals"`
+	// EntryModes lists POS entry modes (DE22)
+	EntryModes []string `json:"entryModes"`
+}
+
+// PanRange holds the PANs whose first len(From) digits are between From and To, both inclusive,
+// e.g. {"from": "476173", "to": "476199"}
+type PanRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Action is the answer to a request. ResponseCode sets DE39 and AuthCode sets DE38, which is
+// generated when empty and the response code is approved. Fields sets response fields and
+// removes the ones set to null. NoResponse leaves the request unanswered, to make the terminal
+// send a reversal, and Disconnect closes the connection instead of answering.
+type Action struct {
+	ResponseCode string
+	AuthCode     string
+	Fields       map[int]*string
+	Delay        time.Duration
+	NoResponse   bool
+	Disconnect   bool
+}
+
+// Matches reports whether request satisfies every condition of m
+func (m *Match) Matches(request *message.Message) bool {
+	if len(m.Mti) > 0 && !contains(m.Mti, request.Fields[0], equal) {
+		return false
+	}
+
+	if len(m.ProcessingCode) > 0 && !contains(m.ProcessingCode, request.Fields[3], strings.HasPrefix) {
+		return false
+	}
+
+	if len(m.PanRanges) > 0 && !m.matchPan(pan(request)) {
+		return false
+	}
+
+	if m.AmountMin != nil || m.AmountMax != nil {
+		amount, err := strconv.ParseInt(request.Fields[4], 10, 64)
+		if err != nil {
+			return false
+		}
+
+		if m.AmountMin != nil && amount < *m.AmountMin {
+			return false
+		}
+
+		if m.AmountMax != nil && amount > *m.AmountMax {
+			return false
+		}
+	}
+
+	if len(m.Terminals) > 0 && !contains(m.Terminals, strings.TrimSpace(request.Fields[41]), equal) {
+		return false
+	}
+
+	if len(m.EntryModes) > 0 && !contains(m.EntryModes, request.Fields[22], equal) {
+		return false
+	}
+
+	return true
+}
+
+func (m *Match) matchPan(pan string) bool {
+	if pan == "" {
+		return false
+	}
+
+	for _, r := range m.PanRanges {
+		if len(pan) < len(r.From) {
+			continue
+		}
+
+		prefix := pan[:len(r.From)]
+		if prefix >= r.From && prefix <= r.To {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pan returns the PAN of request from DE2 or from the track 2 in DE35
+func pan(request *message.Message) string {
+	if value, ok := request.Fields[2]; ok {
+		return value
+	}
+
+	if track, ok := request.Fields[35]; ok {
+		if i := strings.IndexAny(track, "=Dd"); i > 0 {
+			return track[:i]
+		}
+	}
+
+	return ""
+}
+
+func contains(values []string, value string, fn func(string, string) bool) bool {
+	for _, v := range values {
+		if fn(value, strings.TrimSpace(v)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func equal(a, b string) bool {
+	return a == b
+}
@@ -0,0 +1,281 @@
+// Package simulator answers requests with the actions of a rules file, as a stand-in for an issuer host.
+//
+//	{
+//	  "default": {"responseCode": "00"},
+//	  "rules": [
+//	    {"name": "echo", "match": {"mti": ["0800"]}, "responses": [{"responseCode": "00"}]},
+//	    {"name": "timeout", "match": {"amountMin": 100000}, "responses": [{"noResponse": true}]},
+//	    {"name": "second call declined", "match": {"panRanges": [{"from": "476173", "to": "476173"}]},
+//	     "stateFields": [2], "responses": [{"responseCode": "00"}, {"responseCode": "51", "delay": "2s"}]}
+//	  ]
+//	}
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	ctx "github.com/tomasdemarco/go-pos/context"
+	"github.com/tomasdemarco/go-pos/logger"
+	"github.com/tomasdemarco/go-pos/server"
+	"github.com/tomasdemarco/iso8583/message"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Simulator answers each request with the action of the first rule that matches it,
+// or with Default when none does
+type Simulator struct {
+	Rules   []*Rule
+	Default Action
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// SimulatorDto represents the structure of a rules file
+type SimulatorDto struct {
+	Default ActionDto `json:"default"`
+	Rules   []RuleDto `json:"rules"`
+}
+
+// RuleDto represents the structure of a rule in a rules file
+type RuleDto struct {
+	Name        string      `json:"name"`
+	Match       Match       `json:"match"`
+	Responses   []ActionDto `json:"responses"`
+	StateFields []int       `json:"stateFields"`
+	Cycle       bool        `json:"cycle"`
+}
+
+// ActionDto represents the structure of an action in a rules file, with the delay as a duration
+// string like "2s" and the fields by field id
+type ActionDto struct {
+	ResponseCode string             `json:"responseCode"`
+	AuthCode     string             `json:"authCode"`
+	Fields       map[string]*string `json:"fields"`
+	Delay        string             `json:"delay"`
+	NoResponse   bool               `json:"noResponse"`
+	Disconnect   bool               `json:"disconnect"`
+}
+
+func New(rules []*Rule, defaultAction Action) *Simulator {
+	return &Simulator{
+		Rules:   rules,
+		Default: defaultAction,
+		calls:   make(map[string]int),
+	}
+}
+
+// LoadFromJson loads a simulator from a rules file
+func LoadFromJson(path, file string) (*Simulator, error) {
+	byteValue, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToOpenFile, err)
+	}
+
+	var dto SimulatorDto
+	err = json.Unmarshal(byteValue, &dto)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToUnmarshalJSON, err)
+	}
+
+	defaultAction, err := dto.Default.action()
+	if err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+
+	rules := make([]*Rule, 0, len(dto.Rules))
+	for i, v := range dto.Rules {
+		rule := Rule{
+			Name:        v.Name,
+			Match:       v.Match,
+			StateFields: v.StateFields,
+			Cycle:       v.Cycle,
+		}
+
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		if len(v.Responses) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoResponses, rule.Name)
+		}
+
+		for _, a := range v.Responses {
+			action, err := a.action()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rule.Name, err)
+			}
+			rule.Responses = append(rule.Responses, action)
+		}
+
+		for _, r := range rule.Match.PanRanges {
+			if len(r.From) != len(r.To) {
+				return nil, fmt.Errorf("%w: %s: %s-%s", ErrInvalidPanRange, rule.Name, r.From, r.To)
+			}
+		}
+
+		rules = append(rules, &rule)
+	}
+
+	return New(rules, defaultAction), nil
+}
+
+func (a ActionDto) action() (Action, error) {
+	action := Action{
+		ResponseCode: a.ResponseCode,
+		AuthCode:     a.AuthCode,
+		NoResponse:   a.NoResponse,
+		Disconnect:   a.Disconnect,
+	}
+
+	if a.Delay != "" {
+		delay, err := time.ParseDuration(a.Delay)
+		if err != nil {
+			return action, fmt.Errorf("%w: %w", ErrInvalidDelay, err)
+		}
+		action.Delay = delay
+	}
+
+	if len(a.Fields) > 0 {
+		action.Fields = make(map[int]*string, len(a.Fields))
+		for k, v := range a.Fields {
+			fieldId, err := strconv.Atoi(k)
+			if err != nil {
+				return action, fmt.Errorf("%w: %w", ErrInvalidFieldNumber, err)
+			}
+			action.Fields[fieldId] = v
+		}
+	}
+
+	return action, nil
+}
+
+// Handle is the handler function of a server that answers with the simulator
+func (s *Simulator) Handle(c *ctx.RequestContext, srv *server.Server) {
+	name, action := s.Action(c.Request)
+
+	time.Sleep(action.Delay)
+
+	switch {
+	case action.Disconnect:
+		srv.Logger.Info(c, logger.Message, fmt.Sprintf("%s: disconnect", name))
+
+		if c.ClientCtx != nil {
+			_ = c.ClientCtx.Conn.Close()
+		}
+		return
+	case action.NoResponse:
+		srv.Logger.Info(c, logger.Message, fmt.Sprintf("%s: no response", name))
+		return
+	}
+
+	response, err := srv.NewResponse(c.Request)
+	if err != nil {
+		srv.Logger.Error(c, fmt.Errorf("%s: %w", name, err))
+		return
+	}
+	response = apply(response, action)
+
+	err = srv.SendResponse(c, response)
+	if err != nil {
+		srv.Logger.Error(c, fmt.Errorf("error trying to send response message to the client: %w", err))
+	}
+}
+
+// Action returns the name of the rule that matches request, "default" when none does, and the action to take.
+// Every call counts for the stateful responses of the rule.
+func (s *Simulator) Action(request *message.Message) (string, Action) {
+	for i, rule := range s.Rules {
+		if !rule.Match.Matches(request) {
+			continue
+		}
+
+		key := strconv.Itoa(i)
+		for _, fieldId := range rule.StateFields {
+			key += "|" + request.Fields[fieldId]
+		}
+
+		s.mu.Lock()
+		if s.calls == nil {
+			s.calls = make(map[string]int)
+		}
+		call := s.calls[key]
+		s.calls[key]++
+		s.mu.Unlock()
+
+		if call >= len(rule.Responses) {
+			if rule.Cycle {
+				call = call % len(rule.Responses)
+			} else {
+				call = len(rule.Responses) - 1
+			}
+		}
+
+		return rule.Name, rule.Responses[call]
+	}
+
+	return "default", s.Default
+}
+
+// Reset clears the count of calls of the stateful rules
+func (s *Simulator) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = make(map[string]int)
+}
+
+// Response returns the answer to request built by server.NewResponse, plus the response code,
+// the authorization code and the fields of action
+func Response(request *message.Message, action Action) (*message.Message, error) {
+	response, err := server.NewResponse(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return apply(response, action), nil
+}
+
+// apply returns response with the response code, the authorization code and the fields of action.
+// Approved responses get a random authorization code, except network management messages.
+func apply(response *message.Message, action Action) *message.Message {
+	fields := make(map[int]string, len(response.Fields))
+	for fieldId, value := range response.Fields {
+		if fieldId != 1 {
+			fields[fieldId] = value
+		}
+	}
+
+	if action.ResponseCode != "" {
+		fields[39] = action.ResponseCode
+	}
+
+	if action.AuthCode != "" {
+		fields[38] = action.AuthCode
+	} else if logger.ApprovedResponseCodes[action.ResponseCode] && fields[0][1] != '8' {
+		fields[38] = fmt.Sprintf("%06d", rand.Intn(1000000))
+	}
+
+	for fieldId, value := range action.Fields {
+		if value == nil {
+			delete(fields, fieldId)
+		} else {
+			fields[fieldId] = *value
+		}
+	}
+
+	// The bitmap of a message keeps the removed fields, so the result is a new message
+	result := message.NewMessage(response.Packager)
+	result.Header = response.Header
+	result.Trailer = response.Trailer
+	for fieldId, value := range fields {
+		result.SetField(fieldId, value)
+	}
+
+	return result
+}
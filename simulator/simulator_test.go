@@ -0,0 +1,212 @@
+package simulator
+
+import (
+	"errors"
+	"github.com/tomasdemarco/go-pos/gopostest"
+	"github.com/tomasdemarco/go-pos/subfield"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loadPackager(t *testing.T) *packager.Packager {
+	t.Helper()
+
+	pkg, err := subfield.LoadFromJson("../iso8583/packager", "iso87BPackager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+func request(pkg *packager.Packager, fields map[int]string) *message.Message {
+	base := map[int]string{0: "0200", 3: "000000", 4: "000000001000", 22: "051", 41: "00000001"}
+	for fieldId, value := range fields {
+		base[fieldId] = value
+	}
+
+	return gopostest.NewMessage(pkg, base)
+}
+
+func TestMatches(t *testing.T) {
+	pkg := loadPackager(t)
+
+	amount := func(v int64) *int64 {
+		return &v
+	}
+
+	tests := []struct {
+		name   string
+		match  Match
+		fields map[int]string
+		want   bool
+	}{
+		{"empty", Match{}, nil, true},
+		{"mti", Match{Mti: []string{"0100", "0200"}}, nil, true},
+		{"other mti", Match{Mti: []string{"0800"}}, nil, false},
+		{"processing code prefix", Match{ProcessingCode: []string{"20", "00"}}, nil, true},
+		{"other processing code", Match{ProcessingCode: []string{"20"}}, nil, false},
+		{"pan range", Match{PanRanges: []PanRange{{From: "476173", To: "476199"}}}, map[int]string{2: "4761800000000001"}, true},
+		{"pan out of range", Match{PanRanges: []PanRange{{From: "476173", To: "476199"}}}, map[int]string{2: "4762000000000001"}, false},
+		{"pan of track 2", Match{PanRanges: []PanRange{{From: "4761", To: "4761"}}}, map[int]string{35: "4761739001010010=2512"}, true},
+		{"without pan", Match{PanRanges: []PanRange{{From: "4761", To: "4761"}}}, nil, false},
+		{"amount between", Match{AmountMin: amount(1000), AmountMax: amount(1000)}, nil, true},
+		{"amount under the minimum", Match{AmountMin: amount(1001)}, nil, false},
+		{"amount over the maximum", Match{AmountMax: amount(999)}, nil, false},
+		{"padded terminal", Match{Terminals: []string{"T1"}}, map[int]string{41: "T1      "}, true},
+		{"other terminal", Match{Terminals: []string{"00000002"}}, nil, false},
+		{"entry mode", Match{EntryModes: []string{"051", "071"}}, nil, true},
+		{"every condition", Match{Mti: []string{"0200"}, EntryModes: []string{"021"}}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match.Matches(request(pkg, tt.fields)); got != tt.want {
+				t.Errorf("matches is %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAction(t *testing.T) {
+	pkg := loadPackager(t)
+
+	s := New([]*Rule{
+		{Name: "echo", Match: Match{Mti: []string{"0800"}}, Responses: []Action{{ResponseCode: "00"}}},
+		{
+			Name:        "second call declined",
+			Match:       Match{PanRanges: []PanRange{{From: "476173", To: "476173"}}},
+			Responses:   []Action{{ResponseCode: "00"}, {ResponseCode: "51"}},
+			StateFields: []int{2},
+		},
+		{Name: "cycle", Match: Match{Terminals: []string{"00000009"}}, Responses: []Action{{ResponseCode: "00"}, {NoResponse: true}}, Cycle: true},
+	}, Action{ResponseCode: "05"})
+
+	call := func(fields map[int]string) (string, Action) {
+		return s.Action(request(pkg, fields))
+	}
+
+	first := map[int]string{2: "4761730000000001"}
+	second := map[int]string{2: "4761730000000002"}
+
+	// the count is per card, and the last action is repeated
+	for i, want := range []string{"00", "51", "51"} {
+		if name, action := call(first); name != "second call declined" || action.ResponseCode != want {
+			t.Errorf("call %d is %s %q, want %q", i+1, name, action.ResponseCode, want)
+		}
+	}
+
+	if _, action := call(second); action.ResponseCode != "00" {
+		t.Errorf("first call of another card is %q", action.ResponseCode)
+	}
+
+	terminal := map[int]string{41: "00000009"}
+	for i, want := range []bool{false, true, false} {
+		if _, action := call(terminal); action.NoResponse != want {
+			t.Errorf("call %d of the cycle has no response %v, want %v", i+1, action.NoResponse, want)
+		}
+	}
+
+	if name, action := call(map[int]string{0: "0800"}); name != "echo" || action.ResponseCode != "00" {
+		t.Errorf("echo is %s %q", name, action.ResponseCode)
+	}
+
+	if name, action := call(nil); name != "default" || action.ResponseCode != "05" {
+		t.Errorf("default is %s %q", name, action.ResponseCode)
+	}
+
+	s.Reset()
+	if _, action := call(first); action.ResponseCode != "00" {
+		t.Errorf("call after reset is %q", action.ResponseCode)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	pkg := loadPackager(t)
+
+	removed := (*string)(nil)
+	balance := "1002032C000000010000"
+
+	response, err := Response(request(pkg, map[int]string{11: "000001"}), Action{
+		ResponseCode: "00",
+		Fields:       map[int]*string{4: removed, 54: &balance},
+	})
+	if err != nil {
+		t.Fatalf("response: %v", err)
+	}
+
+	if response.Fields[0] != "0210" || response.Fields[39] != "00" || len(response.Fields[38]) != 6 || response.Fields[54] != balance {
+		t.Errorf("response fields %v", response.Fields)
+	}
+
+	if _, ok := response.Fields[4]; ok {
+		t.Errorf("the removed field is in the response")
+	}
+
+	response, err = Response(request(pkg, map[int]string{0: "0800"}), Action{ResponseCode: "00"})
+	if err != nil {
+		t.Fatalf("response: %v", err)
+	}
+
+	if _, ok := response.Fields[38]; ok {
+		t.Errorf("a network management response has an authorization code")
+	}
+}
+
+func TestLoadFromJson(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(file, content string) {
+		err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	write("rules.json", `{
+		"default": {"responseCode": "05"},
+		"rules": [
+			{"match": {"amountMin": 100000}, "responses": [{"noResponse": true}]},
+			{"name": "slow", "match": {"mti": ["0200"]}, "responses": [{"responseCode": "00", "delay": "2s", "fields": {"54": "x", "4": null}}]}
+		]
+	}`)
+
+	s, err := LoadFromJson(dir, "rules.json")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if len(s.Rules) != 2 || s.Rules[0].Name != "rule 1" || s.Default.ResponseCode != "05" {
+		t.Fatalf("rules %+v, default %+v", s.Rules, s.Default)
+	}
+
+	action := s.Rules[1].Responses[0]
+	if action.Delay.Seconds() != 2 || *action.Fields[54] != "x" || action.Fields[4] != nil {
+		t.Errorf("action %+v", action)
+	}
+
+	tests := []struct {
+		content string
+		want    error
+	}{
+		{`{"rules": [{"name": "empty"}]}`, ErrNoResponses},
+		{`{"rules": [{"responses": [{"delay": "soon"}]}]}`, ErrInvalidDelay},
+		{`{"rules": [{"responses": [{"fields": {"DE39": "00"}}]}]}`, ErrInvalidFieldNumber},
+		{`{"rules": [{"match": {"panRanges": [{"from": "4761", "to": "47619"}]}, "responses": [{}]}]}`, ErrInvalidPanRange},
+		{`{"rules": {}}`, ErrFailedToUnmarshalJSON},
+	}
+
+	for _, tt := range tests {
+		write("invalid.json", tt.content)
+		if _, err = LoadFromJson(dir, "invalid.json"); !errors.Is(err, tt.want) {
+			t.Errorf("load %s returned %v, want %v", tt.content, err, tt.want)
+		}
+	}
+
+	if _, err = LoadFromJson(dir, "missing.json"); !errors.Is(err, ErrFailedToOpenFile) {
+		t.Errorf("load returned %v, want %v", err, ErrFailedToOpenFile)
+	}
+}
@@ -0,0 +1,12 @@
+package subfield
+
+import "errors"
+
+var (
+	ErrInvalidSubfields   = errors.New("invalid subfields definition")
+	ErrNotDefined         = errors.New("field has no subfields definition")
+	ErrNotFoundInPackager = errors.New("subfield not found in definition")
+	ErrNotFoundInMessage  = errors.New("subfield not found in message")
+	ErrInvalidValue       = errors.New("value does not match the pattern")
+	ErrInvalidData        = errors.New("invalid subfield data")
+)
@@ -0,0 +1,101 @@
+package subfield
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tomasdemarco/iso8583/packager"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Load loads the subfield definitions of a subfield file like subFieldsVisaDe62.json
+func Load(path, file string) (Subfields, error) {
+	b, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return nil, err
+	}
+
+	var dto map[string]packager.FieldDto
+	err = json.Unmarshal(b, &dto)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidSubfields, file, err)
+	}
+
+	subfields := make(Subfields, len(dto))
+	for k, v := range dto {
+		subfieldId, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: invalid subfield %q", ErrInvalidSubfields, file, k)
+		}
+
+		subfields[subfieldId], err = packager.SetField(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: subfield %d: %w", ErrInvalidSubfields, file, subfieldId, err)
+		}
+	}
+
+	if _, ok := subfields[0]; !ok {
+		return nil, fmt.Errorf("%w: %s: bitmap (subfield 0) not defined", ErrInvalidSubfields, file)
+	}
+
+	return subfields, nil
+}
+
+// LoadPackager loads the subfield files referenced by the fields of a packager file
+// with "subFieldsFile" and "subFieldsFormat": "BITMAP", keyed by field id
+func LoadPackager(path, file string) (map[int]Subfields, error) {
+	b, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return nil, err
+	}
+
+	var dto struct {
+		Fields map[string]struct {
+			SubFieldsFile   string `json:"subFieldsFile"`
+			SubFieldsFormat string `json:"subFieldsFormat"`
+		} `json:"fields"`
+	}
+	err = json.Unmarshal(b, &dto)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidSubfields, file, err)
+	}
+
+	result := make(map[int]Subfields)
+	for k, v := range dto.Fields {
+		if v.SubFieldsFile == "" || v.SubFieldsFormat != "BITMAP" {
+			continue
+		}
+
+		fieldId, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: invalid field %q", ErrInvalidSubfields, file, k)
+		}
+
+		result[fieldId], err = Load(path, v.SubFieldsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// LoadFromJson loads a packager file and registers the subfields of its fields
+func LoadFromJson(path, file string) (*packager.Packager, error) {
+	pkg, err := packager.LoadFromJson(path, file)
+	if err != nil {
+		return nil, err
+	}
+
+	subfields, err := LoadPackager(path, file)
+	if err != nil {
+		return nil, err
+	}
+
+	for fieldId, s := range subfields {
+		Register(pkg, fieldId, s)
+	}
+
+	return pkg, nil
+}
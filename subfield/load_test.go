@@ -0,0 +1,94 @@
+package subfield
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadFromJson loads every packager of the repository with the schema of the iso8583 library
+func TestLoadFromJson(t *testing.T) {
+	for _, tt := range []struct {
+		file   string
+		fields int
+		// number of subfields by field id
+		subfields map[int]int
+	}{
+		{"iso87BPackager.json", 34, map[int]int{}},
+		{"iso87BVisaBase1Packager.json", 44, map[int]int{62: 17, 63: 6, 126: 14}},
+		{"iso87EAmexPackager.json", 45, map[int]int{}},
+	} {
+		pkg, err := LoadFromJson("../iso8583/packager", tt.file)
+		if err != nil {
+			t.Errorf("load %s: %v", tt.file, err)
+			continue
+		}
+
+		if len(pkg.Fields) != tt.fields {
+			t.Errorf("%s has %d fields, want %d", tt.file, len(pkg.Fields), tt.fields)
+		}
+
+		// the secondary bitmap is 8 bytes on the wire
+		if bitmap, ok := pkg.Fields[1]; !ok || bitmap.Length() != 8 {
+			t.Errorf("%s has no bitmap of 8 bytes", tt.file)
+		}
+
+		definitions := Definitions(pkg)
+		if len(definitions) != len(tt.subfields) {
+			t.Errorf("%s has subfields of %d fields, want %d", tt.file, len(definitions), len(tt.subfields))
+		}
+
+		for fieldId, n := range tt.subfields {
+			if len(definitions[fieldId]) != n {
+				t.Errorf("%s field %d has %d subfields, want %d", tt.file, fieldId, len(definitions[fieldId]), n)
+			}
+		}
+	}
+}
+
+// TestLoadPreviousSchema checks that the files written with the schema the packagers had before
+// they were migrated to the one of the library are rejected rather than loaded with wrong fields
+func TestLoadPreviousSchema(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"packager.json": `{
+	"name": "previous",
+	"prefix": {"type": "LLLL", "encoding": "HEX"},
+	"fields": {
+		"000": {"type": "NUMERIC", "length": 4, "name": "MTI", "encoding": "ASCII"},
+		"001": {"type": "Bitmap", "length": 16, "name": "BITMAP", "encoding": "BCD"}
+	}
+}`,
+		"subfieldPackager.json": `{
+	"description": "subfields",
+	"prefix": {"type": "LLLL", "encoding": "BINARY"},
+	"fields": {
+		"000": {"type": "NUMERIC", "length": 4, "description": "MTI", "encoding": "ASCII"},
+		"001": {"type": "BITMAP", "length": 8, "description": "BITMAP", "encoding": "BINARY"},
+		"062": {"type": "BINARY", "length": 255, "description": "CPS", "encoding": "BINARY",
+			"prefix": {"type": "L", "encoding": "BINARY"}, "subFieldsFile": "subfields.json", "subFieldsFormat": "BITMAP"}
+	}
+}`,
+		"subfields.json": `{
+	"00": {"name": "Bitmap", "type": "STRING", "length": 2, "encoding": "BCD"},
+	"01": {"name": "Indicator", "type": "NUMBER", "length": 1, "encoding": "ASCII"}
+}`,
+	}
+
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	if _, err := LoadFromJson(dir, "packager.json"); err == nil {
+		t.Errorf("the packager of the previous schema was loaded")
+	}
+
+	if _, err := LoadFromJson(dir, "subfieldPackager.json"); !errors.Is(err, ErrInvalidSubfields) {
+		t.Errorf("load with the subfields of the previous schema returned %v, want %v", err, ErrInvalidSubfields)
+	}
+}
@@ -0,0 +1,299 @@
+// Package subfield reads and writes the subfields of the fields driven by a private bitmap,
+// like Visa DE62, DE63 and DE126. The value of the field in the message is the hex of its bytes:
+// the bitmap (subfield 0), where bit n flags the presence of subfield n, followed by the subfields.
+//
+//	pkg, err := subfield.LoadFromJson("./iso8583/packager", "iso87BVisaBase1Packager.json")
+//	transactionId, err := subfield.Get(msg, 62, 2)
+//	err = subfield.Set(response, 62, 1, "Y")
+package subfield
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/tomasdemarco/go-pos/validate"
+	"github.com/tomasdemarco/iso8583/encoding"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"github.com/tomasdemarco/iso8583/packager/field"
+	"runtime"
+	"sort"
+	"sync"
+	"unsafe"
+)
+
+// Subfields are the definitions of the subfields of a field, subfield 0 is the bitmap
+type Subfields map[int]field.Packager
+
+// The definitions are kept by the address of the packager rather than by its pointer, so that the
+// registry does not keep a packager alive: a finalizer removes them once the packager is collected,
+// e.g. a version replaced by a reload once its connections are closed. The memory of a collected
+// packager is not reused before its finalizer ran, so an address is never shared by two packagers.
+var (
+	mu       sync.RWMutex
+	registry = make(map[uintptr]map[int]Subfields)
+)
+
+func key(pkg *packager.Packager) uintptr {
+	return uintptr(unsafe.Pointer(pkg))
+}
+
+// Register sets the subfields of fieldId for the messages of pkg, until pkg is collected or
+// unregistered. pkg must be allocated on its own, e.g. by LoadFromJson or as &packager.Packager{}.
+func Register(pkg *packager.Packager, fieldId int, subfields Subfields) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key(pkg)
+	if registry[k] == nil {
+		registry[k] = make(map[int]Subfields)
+		runtime.SetFinalizer(pkg, Unregister)
+	}
+	registry[k][fieldId] = subfields
+}
+
+// Unregister removes the subfields registered for pkg
+func Unregister(pkg *packager.Packager) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key(pkg)
+	if registry[k] != nil {
+		delete(registry, k)
+		runtime.SetFinalizer(pkg, nil)
+	}
+}
+
+// Copy registers the subfields of src for dst, a copy of src like the one of validate.Permissive.
+// The subfields belong to a packager and are not carried by its copies otherwise.
+func Copy(dst, src *packager.Packager) {
+	for fieldId, subfields := range Definitions(src) {
+		Register(dst, fieldId, subfields)
+	}
+}
+
+// Definitions returns the subfields registered for the fields of pkg
+func Definitions(pkg *packager.Packager) map[int]Subfields {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	definitions := make(map[int]Subfields, len(registry[key(pkg)]))
+	for fieldId, subfields := range registry[key(pkg)] {
+		definitions[fieldId] = subfields
+	}
+
+	return definitions
+}
+
+// Lookup returns the subfields of fieldId registered for pkg
+func Lookup(pkg *packager.Packager, fieldId int) (Subfields, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	subfields, ok := registry[key(pkg)][fieldId]
+	return subfields, ok
+}
+
+// Get returns the value of subfieldId of fieldId in msg
+func Get(msg *message.Message, fieldId, subfieldId int) (string, error) {
+	values, err := Values(msg, fieldId)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := values[subfieldId]
+	if !ok {
+		return "", fmt.Errorf("%w: %d.%d", ErrNotFoundInMessage, fieldId, subfieldId)
+	}
+
+	return value, nil
+}
+
+// Set sets subfieldId of fieldId in msg, keeping the other subfields and updating the bitmap.
+// The value must match the pattern of the subfield.
+func Set(msg *message.Message, fieldId, subfieldId int, value string) error {
+	subfields, ok := Lookup(msg.Packager, fieldId)
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrNotDefined, fieldId)
+	}
+
+	values := make(map[int]string)
+	if current, ok := msg.Fields[fieldId]; ok && current != "" {
+		var err error
+		values, err = subfields.Unpack(current)
+		if err != nil {
+			return err
+		}
+	}
+	values[subfieldId] = value
+
+	packed, err := subfields.Pack(values)
+	if err != nil {
+		return err
+	}
+
+	msg.SetField(fieldId, packed)
+	return nil
+}
+
+// Values returns the subfields of fieldId in msg by subfield id, including the bitmap as subfield 0
+func Values(msg *message.Message, fieldId int) (map[int]string, error) {
+	subfields, ok := Lookup(msg.Packager, fieldId)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrNotDefined, fieldId)
+	}
+
+	value, err := msg.GetField(fieldId)
+	if err != nil {
+		return nil, err
+	}
+
+	return subfields.Unpack(value)
+}
+
+// Unpack returns the subfields of value, the hex of the field, by subfield id.
+// Values that do not match their pattern are returned too.
+func (s Subfields) Unpack(value string) (map[int]string, error) {
+	raw, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidData, err)
+	}
+
+	bitmapValue, offset, err := unpack(s[0], raw, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bitmap: %w", ErrInvalidData, err)
+	}
+
+	bits, err := hex.DecodeString(bitmapValue)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bitmap: %w", ErrInvalidData, err)
+	}
+
+	values := map[int]string{0: bitmapValue}
+	for i := 0; i < len(bits)*8; i++ {
+		if bits[i/8]&(0x80>>(i%8)) == 0 {
+			continue
+		}
+
+		subfieldId := i + 1
+
+		fldPkg, ok := s[subfieldId]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", ErrNotFoundInPackager, subfieldId)
+		}
+
+		value, length, err := unpack(fldPkg, raw, offset)
+		if err != nil {
+			return nil, fmt.Errorf("%w: subfield %d: %w", ErrInvalidData, subfieldId, err)
+		}
+
+		values[subfieldId] = value
+		offset += length
+	}
+
+	return values, nil
+}
+
+// Pack returns the hex of the field with the bitmap of values and the subfields in order,
+// subfield 0 in values is ignored
+func (s Subfields) Pack(values map[int]string) (string, error) {
+	bits := make([]byte, bitmapLength(s[0]))
+
+	subfieldIds := make([]int, 0, len(values))
+	for subfieldId := range values {
+		if subfieldId == 0 {
+			continue
+		}
+
+		if subfieldId > len(bits)*8 {
+			return "", fmt.Errorf("%w: %d", ErrNotFoundInPackager, subfieldId)
+		}
+
+		subfieldIds = append(subfieldIds, subfieldId)
+		bits[(subfieldId-1)/8] |= 0x80 >> ((subfieldId - 1) % 8)
+	}
+	sort.Ints(subfieldIds)
+
+	raw, err := pack(s[0], fmt.Sprintf("%X", bits))
+	if err != nil {
+		return "", fmt.Errorf("%w: bitmap: %w", ErrInvalidData, err)
+	}
+
+	for _, subfieldId := range subfieldIds {
+		fldPkg, ok := s[subfieldId]
+		if !ok {
+			return "", fmt.Errorf("%w: %d", ErrNotFoundInPackager, subfieldId)
+		}
+
+		value := values[subfieldId]
+		if pattern := fldPkg.Pattern(); pattern != nil && !pattern.MatchString(value) {
+			return "", fmt.Errorf("%w: subfield %d %s", ErrInvalidValue, subfieldId, pattern.String())
+		}
+
+		b, err := pack(fldPkg, value)
+		if err != nil {
+			return "", fmt.Errorf("%w: subfield %d: %w", ErrInvalidData, subfieldId, err)
+		}
+		raw = append(raw, b...)
+	}
+
+	return fmt.Sprintf("%X", raw), nil
+}
+
+// pack packs a subfield. The BCD subfields carry hex data, like the bitmap, which the BCD
+// encoder of the packager does not support, so they are packed as the bytes of the hex value.
+func pack(fldPkg field.Packager, value string) ([]byte, error) {
+	if _, ok := fldPkg.Encoder().(*encoding.BCD); !ok {
+		b, _, err := fldPkg.Pack(value)
+		return b, err
+	}
+
+	if len(value)%2 == 1 {
+		value = "0" + value
+	}
+
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) != fldPkg.Length() {
+		return nil, fmt.Errorf("expected %d bytes, got %d", fldPkg.Length(), len(b))
+	}
+
+	return b, nil
+}
+
+// unpack unpacks a subfield without checking its pattern, recovering from the panics
+// of the packager on short data. The BCD subfields are returned as the hex of their bytes.
+func unpack(fldPkg field.Packager, raw []byte, offset int) (value string, length int, err error) {
+	if fldPkg == nil {
+		return "", 0, ErrNotFoundInPackager
+	}
+
+	if _, ok := fldPkg.Encoder().(*encoding.BCD); ok {
+		length = fldPkg.Length()
+		if offset+length > len(raw) {
+			return "", 0, fmt.Errorf("data too short at %d", offset)
+		}
+
+		return fmt.Sprintf("%X", raw[offset:offset+length]), length, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("data too short at %d: %v", offset, r)
+		}
+	}()
+
+	return validate.PermissiveField(fldPkg).Unpack(raw, offset)
+}
+
+// bitmapLength returns the length in bytes of the bitmap defined by fldPkg
+func bitmapLength(fldPkg field.Packager) int {
+	switch fldPkg.Encoder().(type) {
+	case *encoding.BCD, *encoding.BINARY:
+		return fldPkg.Length()
+	default:
+		return fldPkg.Length() / 2
+	}
+}
@@ -0,0 +1,98 @@
+package subfield
+
+import (
+	"errors"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func loadPackager(t *testing.T) *packager.Packager {
+	t.Helper()
+
+	pkg, err := LoadFromJson("../iso8583/packager", "iso87BVisaBase1Packager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+func TestSetGet(t *testing.T) {
+	pkg := loadPackager(t)
+
+	msg := message.NewMessage(pkg)
+	msg.SetField(0, "0110")
+
+	err := Set(msg, 62, 2, "0123456789ABCDEF")
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	err = Set(msg, 62, 1, "Y ")
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	for subfieldId, want := range map[int]string{1: "Y ", 2: "0123456789ABCDEF"} {
+		got, err := Get(msg, 62, subfieldId)
+		if err != nil || got != want {
+			t.Errorf("subfield 62.%d is %q, %v, want %q", subfieldId, got, err, want)
+		}
+	}
+
+	if _, err = Get(msg, 62, 3); !errors.Is(err, ErrNotFoundInMessage) {
+		t.Errorf("get returned %v, want %v", err, ErrNotFoundInMessage)
+	}
+
+	if err = Set(msg, 4, 1, "1"); !errors.Is(err, ErrNotDefined) {
+		t.Errorf("set returned %v, want %v", err, ErrNotDefined)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	pkg := loadPackager(t)
+
+	copied := &packager.Packager{Description: pkg.Description, Prefix: pkg.Prefix, Fields: pkg.Fields}
+	if _, ok := Lookup(copied, 62); ok {
+		t.Fatalf("a copy has the subfields of the packager")
+	}
+
+	Copy(copied, pkg)
+	if len(Definitions(copied)) != len(Definitions(pkg)) {
+		t.Errorf("the copy has %d definitions, want %d", len(Definitions(copied)), len(Definitions(pkg)))
+	}
+
+	Unregister(copied)
+	if _, ok := Lookup(copied, 62); ok {
+		t.Errorf("the subfields of the copy are registered after unregistering it")
+	}
+
+	if _, ok := Lookup(pkg, 62); !ok {
+		t.Errorf("unregistering the copy removed the subfields of the packager")
+	}
+}
+
+// TestCollected checks that the registry does not keep a packager alive
+func TestCollected(t *testing.T) {
+	k := key(loadPackager(t))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		runtime.GC()
+
+		mu.RLock()
+		_, ok := registry[k]
+		mu.RUnlock()
+
+		if !ok {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("the subfields of a collected packager are still registered")
+}
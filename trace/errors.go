@@ -0,0 +1,7 @@
+package trace
+
+import "errors"
+
+var (
+	ErrOpenExportFile = errors.New("failed to open trace export file")
+)
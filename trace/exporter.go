@@ -0,0 +1,150 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Exporter receives the spans when they end
+type Exporter interface {
+	Export(span *Span) error
+}
+
+// MemoryExporter keeps the exported spans in memory, useful for tests
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (e *MemoryExporter) Export(span *Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, span)
+
+	return nil
+}
+
+// Spans returns the exported spans
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]*Span(nil), e.spans...)
+}
+
+// Trace returns the exported spans of traceId
+func (e *MemoryExporter) Trace(traceId string) []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	spans := make([]*Span, 0)
+	for _, span := range e.spans {
+		if span.TraceId == traceId {
+			spans = append(spans, span)
+		}
+	}
+
+	return spans
+}
+
+// Reset removes the exported spans
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
+
+// JSONExporter writes each span as a JSON line
+type JSONExporter struct {
+	mu     sync.Mutex
+	writer io.Writer
+	closer io.Closer
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{writer: w}
+}
+
+// NewJSONFileExporter appends the spans to the file in path
+func NewJSONFileExporter(path string) (*JSONExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpenExportFile, err)
+	}
+
+	return &JSONExporter{writer: file, closer: file}, nil
+}
+
+type spanDto struct {
+	TraceId      string            `json:"traceId"`
+	SpanId       string            `json:"spanId"`
+	ParentSpanId string            `json:"parentSpanId,omitempty"`
+	Name         string            `json:"name"`
+	Start        string            `json:"start"`
+	End          string            `json:"end"`
+	Duration     float64           `json:"durationMs"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Phases       []phaseDto        `json:"phases,omitempty"`
+}
+
+type phaseDto struct {
+	Name     string  `json:"name"`
+	Start    string  `json:"start"`
+	Duration float64 `json:"durationMs"`
+}
+
+func (e *JSONExporter) Export(span *Span) error {
+	dto := spanDto{
+		TraceId:      span.TraceId,
+		SpanId:       span.SpanId,
+		ParentSpanId: span.ParentSpanId,
+		Name:         span.Name,
+		Start:        span.Start.Format(time.RFC3339Nano),
+		End:          span.End.Format(time.RFC3339Nano),
+		Duration:     milliseconds(span.End.Sub(span.Start)),
+		Attributes:   span.Attributes,
+	}
+
+	for _, phase := range span.Phases {
+		dto.Phases = append(dto.Phases, phaseDto{
+			Name:     phase.Name,
+			Start:    phase.Start.Format(time.RFC3339Nano),
+			Duration: milliseconds(phase.Duration),
+		})
+	}
+
+	value, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err = e.writer.Write(append(value, '\n'))
+
+	return err
+}
+
+// Close closes the file opened by NewJSONFileExporter
+func (e *JSONExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+
+	return e.closer.Close()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1e6
+}
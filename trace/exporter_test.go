@@ -0,0 +1,138 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryExporter(t *testing.T) {
+	exporter := NewMemoryExporter()
+
+	parent := NewSpan("request")
+	child := NewChildSpan(parent, "upstream")
+	other := NewSpan("request")
+
+	for _, span := range []*Span{child, other, parent} {
+		err := span.Stop(exporter)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+	}
+
+	spans := exporter.Trace(parent.TraceId)
+	if len(spans) != 2 || spans[0].SpanId != child.SpanId || spans[1].SpanId != parent.SpanId {
+		t.Errorf("trace %+v, want the child and the parent", spans)
+	}
+
+	if spans = exporter.Trace("unknown"); spans == nil || len(spans) != 0 {
+		t.Errorf("trace of an unknown id %v, want empty", spans)
+	}
+
+	// the returned slice is a copy
+	spans = exporter.Spans()
+	spans[0] = nil
+	if exporter.Spans()[0] == nil || len(exporter.Spans()) != 3 {
+		t.Errorf("the spans of the exporter were changed through the returned slice")
+	}
+
+	exporter.Reset()
+	if len(exporter.Spans()) != 0 {
+		t.Errorf("spans after reset %v", exporter.Spans())
+	}
+}
+
+func TestJSONExporter(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	span := &Span{
+		TraceId:      "0af7651916cd43dd8448eb211c80319c",
+		SpanId:       "b7ad6b7169203331",
+		ParentSpanId: "00f067aa0ba902b7",
+		Name:         "upstream",
+		Start:        start,
+		End:          start.Add(12500 * time.Microsecond),
+		Attributes:   map[string]string{"mti": "0200"},
+		Phases:       []Phase{{Name: PhaseWrite, Start: start.Add(time.Millisecond), Duration: 250 * time.Microsecond}},
+	}
+
+	var out bytes.Buffer
+	exporter := NewJSONExporter(&out)
+
+	err := exporter.Export(span)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	// a span without parent, attributes or phases leaves them out
+	err = exporter.Export(&Span{TraceId: span.TraceId, SpanId: "00f067aa0ba902b7", Name: "request", Start: start, End: start})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	want := `{"traceId":"0af7651916cd43dd8448eb211c80319c","spanId":"b7ad6b7169203331","parentSpanId":"00f067aa0ba902b7",` +
+		`"name":"upstream","start":"2024-01-01T12:00:00Z","end":"2024-01-01T12:00:00.0125Z","durationMs":12.5,` +
+		`"attributes":{"mti":"0200"},"phases":[{"name":"write","start":"2024-01-01T12:00:00.001Z","durationMs":0.25}]}` + "\n" +
+		`{"traceId":"0af7651916cd43dd8448eb211c80319c","spanId":"00f067aa0ba902b7","name":"request",` +
+		`"start":"2024-01-01T12:00:00Z","end":"2024-01-01T12:00:00Z","durationMs":0}` + "\n"
+
+	if out.String() != want {
+		t.Errorf("export\n%s\nwant\n%s", out.String(), want)
+	}
+
+	if err = exporter.Close(); err != nil {
+		t.Errorf("close of an exporter without file returned %v", err)
+	}
+}
+
+func TestJSONFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+
+	// the spans are appended to the file of a previous exporter
+	for i := 0; i < 2; i++ {
+		exporter, err := NewJSONFileExporter(path)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+
+		err = NewSpan("request").Stop(exporter)
+		if err != nil {
+			t.Fatalf("stop: %v", err)
+		}
+
+		err = exporter.Close()
+		if err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var dto spanDto
+		if err = json.Unmarshal(scanner.Bytes(), &dto); err != nil || dto.Name != "request" {
+			t.Errorf("line %s: %v", scanner.Text(), err)
+		}
+		lines++
+	}
+
+	if lines != 2 {
+		t.Errorf("%d spans in the file, want 2", lines)
+	}
+
+	_, err = NewJSONFileExporter(filepath.Join(t.TempDir(), "missing", "spans.jsonl"))
+	if !errors.Is(err, ErrOpenExportFile) {
+		t.Errorf("new returned %v, want %v", err, ErrOpenExportFile)
+	}
+}
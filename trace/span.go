@@ -0,0 +1,179 @@
+// Package trace records spans with the timing of each phase of a request
+// and hands them to an Exporter when they end.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Phase names recorded by server and client
+const (
+	PhaseRead     = "read"
+	PhaseUnpack   = "unpack"
+	PhaseHandler  = "handler"
+	PhaseUpstream = "upstream"
+	PhasePack     = "pack"
+	PhaseWrite    = "write"
+)
+
+// Span is a timed operation of a trace, a server request or an upstream client call
+type Span struct {
+	mu sync.Mutex
+
+	TraceId      string
+	SpanId       string
+	ParentSpanId string
+	Name         string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+	Phases       []Phase
+
+	open  map[string]time.Time
+	ended bool
+}
+
+// Phase is the timing of one step of a span
+type Phase struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+}
+
+// NewSpan starts a span of a new trace
+func NewSpan(name string) *Span {
+	return &Span{
+		TraceId:    newId(16),
+		SpanId:     newId(8),
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: make(map[string]string),
+		open:       make(map[string]time.Time),
+	}
+}
+
+// NewChildSpan starts a span in the trace of parent
+func NewChildSpan(parent *Span, name string) *Span {
+	span := NewSpan(name)
+	if parent != nil {
+		span.TraceId = parent.TraceId
+		span.ParentSpanId = parent.SpanId
+	}
+
+	return span
+}
+
+// SetAttribute sets an attribute of the span
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Attributes[key] = value
+}
+
+// Begin starts the phase name, it is recorded when Finish is called
+func (s *Span) Begin(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.open[name] = time.Now()
+}
+
+// Finish records the phase started with Begin, it does nothing when the phase is not open
+func (s *Span) Finish(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, ok := s.open[name]
+	if !ok {
+		return
+	}
+
+	delete(s.open, name)
+	s.Phases = append(s.Phases, Phase{Name: name, Start: start, Duration: time.Since(start)})
+}
+
+// AddPhase records a phase that started at start and ended at end
+func (s *Span) AddPhase(name string, start, end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Phases = append(s.Phases, Phase{Name: name, Start: start, Duration: end.Sub(start)})
+}
+
+// Phase returns the recorded phase name
+func (s *Span) Phase(name string) (Phase, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, phase := range s.Phases {
+		if phase.Name == name {
+			return phase, true
+		}
+	}
+
+	return Phase{}, false
+}
+
+// Stopped reports whether Stop was called
+func (s *Span) Stopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ended
+}
+
+// Stop ends the span and exports it, the span is exported only once.
+// Open phases are discarded.
+func (s *Span) Stop(exporter Exporter) error {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.ended = true
+	s.End = time.Now()
+	s.open = make(map[string]time.Time)
+	s.mu.Unlock()
+
+	if exporter == nil {
+		return nil
+	}
+
+	return exporter.Export(s.snapshot())
+}
+
+// snapshot returns a copy of the span that is safe to keep after the span is exported
+func (s *Span) snapshot() *Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := Span{
+		TraceId:      s.TraceId,
+		SpanId:       s.SpanId,
+		ParentSpanId: s.ParentSpanId,
+		Name:         s.Name,
+		Start:        s.Start,
+		End:          s.End,
+		Attributes:   make(map[string]string, len(s.Attributes)),
+		Phases:       append([]Phase(nil), s.Phases...),
+		ended:        true,
+	}
+
+	for k, v := range s.Attributes {
+		span.Attributes[k] = v
+	}
+
+	return &span
+}
+
+func newId(size int) string {
+	b := make([]byte, size)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
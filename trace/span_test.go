@@ -0,0 +1,142 @@
+package trace
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewSpan(t *testing.T) {
+	parent := NewSpan("request")
+	if len(parent.TraceId) != 32 || len(parent.SpanId) != 16 || parent.ParentSpanId != "" {
+		t.Errorf("span ids %q %q %q", parent.TraceId, parent.SpanId, parent.ParentSpanId)
+	}
+
+	child := NewChildSpan(parent, "upstream")
+	if child.TraceId != parent.TraceId || child.ParentSpanId != parent.SpanId || child.SpanId == parent.SpanId {
+		t.Errorf("child span ids %q %q %q", child.TraceId, child.SpanId, child.ParentSpanId)
+	}
+
+	// without parent the child starts a new trace
+	if orphan := NewChildSpan(nil, "upstream"); orphan.TraceId == parent.TraceId || orphan.ParentSpanId != "" {
+		t.Errorf("span without parent in trace %q of parent %q", orphan.TraceId, orphan.ParentSpanId)
+	}
+}
+
+func TestPhases(t *testing.T) {
+	span := NewSpan("request")
+
+	span.Begin(PhaseHandler)
+	time.Sleep(10 * time.Millisecond)
+	span.Finish(PhaseHandler)
+
+	// a phase that was not begun is not recorded
+	span.Finish(PhaseWrite)
+
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	span.AddPhase(PhaseRead, start, start.Add(3*time.Millisecond))
+
+	handler, ok := span.Phase(PhaseHandler)
+	if !ok || handler.Duration < 10*time.Millisecond || handler.Start.Before(span.Start) {
+		t.Errorf("phase %+v, want at least 10ms after the start of the span", handler)
+	}
+
+	if read, ok := span.Phase(PhaseRead); !ok || read.Start != start || read.Duration != 3*time.Millisecond {
+		t.Errorf("phase %+v, want 3ms from %s", read, start)
+	}
+
+	if _, ok = span.Phase(PhaseWrite); ok {
+		t.Errorf("the phase that was not begun was recorded")
+	}
+
+	// the phases still open when the span stops are discarded
+	span.Begin(PhasePack)
+
+	exporter := NewMemoryExporter()
+	err := span.Stop(exporter)
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	span.Finish(PhasePack)
+
+	if phases := exporter.Spans()[0].Phases; len(phases) != 2 || phases[0].Name != PhaseHandler || phases[1].Name != PhaseRead {
+		t.Errorf("exported phases %+v, want handler and read", phases)
+	}
+
+	if _, ok = span.Phase(PhasePack); ok {
+		t.Errorf("the phase open when the span stopped was recorded")
+	}
+}
+
+func TestStop(t *testing.T) {
+	span := NewSpan("request")
+	exporter := NewMemoryExporter()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := span.Stop(exporter)
+			if err != nil {
+				t.Errorf("stop: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+
+	if !span.Stopped() || spans[0].End.Before(spans[0].Start) || spans[0].End != span.End {
+		t.Errorf("span stopped %v, from %s to %s", span.Stopped(), spans[0].Start, spans[0].End)
+	}
+
+	// a span stopped without exporter is not exported later
+	other := NewSpan("request")
+	if err := other.Stop(nil); err != nil || !other.Stopped() {
+		t.Fatalf("stop without exporter returned %v", err)
+	}
+
+	if err := other.Stop(exporter); err != nil || len(exporter.Spans()) != 1 {
+		t.Errorf("the span stopped without exporter was exported")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	span := NewSpan("request")
+	span.SetAttribute("mti", "0200")
+	span.AddPhase(PhaseRead, span.Start, span.Start.Add(time.Millisecond))
+
+	exporter := NewMemoryExporter()
+	err := span.Stop(exporter)
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	exported := exporter.Spans()[0]
+	if exported == span {
+		t.Fatalf("the span was exported instead of a copy")
+	}
+
+	// the changes made to the span after it is exported do not reach the exported copy
+	span.SetAttribute("mti", "0400")
+	span.SetAttribute("rc", "00")
+	span.AddPhase(PhaseWrite, span.Start, span.End)
+
+	if len(exported.Attributes) != 1 || exported.Attributes["mti"] != "0200" {
+		t.Errorf("exported attributes %v", exported.Attributes)
+	}
+
+	if len(exported.Phases) != 1 || exported.Phases[0].Name != PhaseRead {
+		t.Errorf("exported phases %+v", exported.Phases)
+	}
+
+	if exported.TraceId != span.TraceId || exported.SpanId != span.SpanId || exported.Name != span.Name || !exported.Stopped() {
+		t.Errorf("exported span %+v", exported)
+	}
+}
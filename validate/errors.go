@@ -0,0 +1,8 @@
+package validate
+
+import "errors"
+
+var (
+	ErrInvalidValue  = errors.New("value does not match the pattern")
+	ErrNotInPackager = errors.New("field not found in packager")
+)
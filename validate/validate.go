@@ -0,0 +1,158 @@
+// Package validate checks the fields of a message against the patterns of its packager.
+//
+// The unpack of the iso8583 library stops at the first field that does not match its pattern.
+// Unpack reads the message with a permissive copy of its packager and then reports every invalid field.
+package validate
+
+import (
+	"fmt"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"github.com/tomasdemarco/iso8583/packager/field"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FieldError is a field whose value is not valid for the packager.
+// The value is not part of the error, so it can be logged without exposing card data.
+type FieldError struct {
+	FieldId     int
+	Description string
+	Pattern     string
+	Err         error
+}
+
+func (e *FieldError) Error() string {
+	if e.Pattern != "" {
+		return fmt.Sprintf("field %d (%s): %s %s", e.FieldId, e.Description, e.Err, e.Pattern)
+	}
+
+	return fmt.Sprintf("field %d (%s): %s", e.FieldId, e.Description, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Errors are the invalid fields of a message, ordered by field id
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	values := make([]string, len(e))
+	for i, err := range e {
+		values[i] = err.Error()
+	}
+
+	return strings.Join(values, "; ")
+}
+
+// Has reports whether fieldId is one of the invalid fields
+func (e Errors) Has(fieldId int) bool {
+	for _, err := range e {
+		if err.FieldId == fieldId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// Message returns Errors with every field of msg that its packager does not define
+// or whose value does not match the pattern, or nil when all of them are valid
+func Message(msg *message.Message) error {
+	fieldIds := make([]int, 0, len(msg.Fields))
+	for fieldId := range msg.Fields {
+		if fieldId != 1 {
+			fieldIds = append(fieldIds, fieldId)
+		}
+	}
+	sort.Ints(fieldIds)
+
+	var errs Errors
+	for _, fieldId := range fieldIds {
+		fldPkg, ok := msg.Packager.Fields[fieldId]
+		if !ok {
+			errs = append(errs, &FieldError{FieldId: fieldId, Err: ErrNotInPackager})
+			continue
+		}
+
+		pattern := fldPkg.Pattern()
+		if pattern != nil && !pattern.MatchString(msg.Fields[fieldId]) {
+			errs = append(errs, &FieldError{
+				FieldId:     fieldId,
+				Description: description(fldPkg),
+				Pattern:     pattern.String(),
+				Err:         ErrInvalidValue,
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Unpack unpacks raw into msg with permissive, the Permissive copy of the packager of msg, so it
+// does not stop at the fields that do not match their pattern, then returns the validation of
+// Message. Any other unpack error is returned as is.
+func Unpack(msg *message.Message, permissive *packager.Packager, raw []byte) error {
+	pkg := msg.Packager
+
+	msg.Packager = permissive
+	err := msg.Unpack(raw)
+	msg.Packager = pkg
+
+	if err != nil {
+		return err
+	}
+
+	return Message(msg)
+}
+
+// anyValue replaces the patterns of the packager, it matches every value
+var anyValue = regexp.MustCompile("")
+
+// Permissive returns a copy of pkg whose fields accept any value. The copy is meant to be kept
+// with pkg, e.g. by the connection that unpacks with it, rather than built for every message.
+func Permissive(pkg *packager.Packager) *packager.Packager {
+	p := packager.Packager{
+		Description: pkg.Description,
+		Prefix:      pkg.Prefix,
+		Fields:      make(map[int]field.Packager, len(pkg.Fields)),
+	}
+
+	for fieldId, fldPkg := range pkg.Fields {
+		p.Fields[fieldId] = PermissiveField(fldPkg)
+	}
+
+	return &p
+}
+
+// PermissiveField returns a copy of fldPkg that accepts any value
+func PermissiveField(fldPkg field.Packager) field.Packager {
+	if fld, ok := fldPkg.(*field.Field); ok {
+		return field.NewField(fld.Description, fld.Type, fld.Length(), anyValue, fld.Encoder(), fld.Prefixer(), fld.Padder())
+	}
+
+	return fldPkg
+}
+
+func description(fldPkg field.Packager) string {
+	if fld, ok := fldPkg.(*field.Field); ok {
+		return fld.Description
+	}
+
+	return ""
+}
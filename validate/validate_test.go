@@ -0,0 +1,114 @@
+package validate
+
+import (
+	"errors"
+	"github.com/tomasdemarco/iso8583/message"
+	"github.com/tomasdemarco/iso8583/packager"
+	"testing"
+)
+
+func loadPackager(t *testing.T) *packager.Packager {
+	t.Helper()
+
+	pkg, err := packager.LoadFromJson("../iso8583/packager", "iso87BPackager.json")
+	if err != nil {
+		t.Fatalf("load packager: %v", err)
+	}
+
+	return pkg
+}
+
+// pack returns fields packed with pkg, invalid values included
+func pack(t *testing.T, pkg *packager.Packager, fields map[int]string) []byte {
+	t.Helper()
+
+	msg := message.NewMessage(pkg)
+	for fieldId, value := range fields {
+		msg.SetField(fieldId, value)
+	}
+
+	raw, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+
+	return raw
+}
+
+func TestUnpack(t *testing.T) {
+	pkg := loadPackager(t)
+	permissive := Permissive(pkg)
+
+	raw := pack(t, permissive, map[int]string{0: "0200", 3: "000000", 37: "ABCDEFGHIJKL", 41: "TERM0001"})
+
+	if err := message.NewMessage(pkg).Unpack(raw); err == nil {
+		t.Fatalf("the packager accepted the invalid fields")
+	}
+
+	msg := message.NewMessage(pkg)
+	err := Unpack(msg, permissive, raw)
+
+	var invalid Errors
+	if !errors.As(err, &invalid) {
+		t.Fatalf("unpack returned %v, want Errors", err)
+	}
+
+	if len(invalid) != 2 || invalid[0].FieldId != 37 || invalid[1].FieldId != 41 {
+		t.Fatalf("invalid fields %v, want 37 and 41", err)
+	}
+
+	if !errors.Is(err, ErrInvalidValue) || !invalid.Has(41) || invalid.Has(3) {
+		t.Errorf("unexpected errors %v", err)
+	}
+
+	if msg.Packager != pkg {
+		t.Errorf("the message keeps the permissive packager")
+	}
+
+	if msg.Fields[41] != "TERM0001" || msg.Fields[3] != "000000" {
+		t.Errorf("fields %v were not unpacked", msg.Fields)
+	}
+}
+
+func TestUnpackValid(t *testing.T) {
+	pkg := loadPackager(t)
+
+	raw := pack(t, pkg, map[int]string{0: "0200", 3: "000000", 41: "00000001"})
+
+	err := Unpack(message.NewMessage(pkg), Permissive(pkg), raw)
+	if err != nil {
+		t.Errorf("unpack: %v", err)
+	}
+}
+
+func TestMessageNotInPackager(t *testing.T) {
+	pkg := loadPackager(t)
+
+	msg := message.NewMessage(pkg)
+	msg.SetField(0, "0200")
+	msg.Fields[200] = "x"
+
+	err := Message(msg)
+	if !errors.Is(err, ErrNotInPackager) {
+		t.Errorf("message returned %v, want %v", err, ErrNotInPackager)
+	}
+}
+
+func TestPermissive(t *testing.T) {
+	pkg := loadPackager(t)
+	permissive := Permissive(pkg)
+
+	if len(permissive.Fields) != len(pkg.Fields) || permissive.Prefix != pkg.Prefix {
+		t.Fatalf("the copy does not have the fields and prefix of the packager")
+	}
+
+	for fieldId, fldPkg := range pkg.Fields {
+		if permissive.Fields[fieldId] == fldPkg && fldPkg.Pattern() != nil {
+			t.Errorf("field %d is shared with the packager", fieldId)
+		}
+	}
+
+	if pkg.Fields[41].Pattern().MatchString("TERM0001") {
+		t.Errorf("the packager was changed")
+	}
+}